@@ -0,0 +1,371 @@
+package i2c
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gobot.io/x/gobot"
+)
+
+const (
+	bme280RegisterCalibDigH1      = 0xa1
+	bme280RegisterCalibDigH2      = 0xe1
+	bme280RegisterControlHumidity = 0xf2
+
+	// bme280RawHumidityReset is the reset value of the 16 bit humidity data.
+	bme280RawHumidityReset = 0x8000
+)
+
+const (
+	// Magnus-Tetens coefficients, valid from -45 to 60 celsius degrees.
+	bme280MagnusB = 17.62
+	bme280MagnusC = 243.12
+)
+
+type bme280HumidityCalibrationCoefficients struct {
+	h1 uint8
+	h2 int16
+	h3 uint8
+	h4 int16
+	h5 int16
+	h6 int8
+}
+
+// BME280Driver is the gobot driver for the Bosch humidity/pressure sensor BME280.
+// It is register compatible with the BMP280, and adds humidity measurement.
+// Device datasheet: https://cdn-shop.adafruit.com/datasheets/BST-BME280_DS001-10.pdf
+type BME280Driver struct {
+	*BMP280Driver
+
+	hc              *bme280HumidityCalibrationCoefficients
+	humOversampling BMP280Oversampling
+}
+
+// NewBME280Driver creates a new driver with specified i2c interface.
+// Params:
+//		conn Connector - the Adaptor to use with this Driver
+//
+// Optional params:
+//		i2c.WithBus(int):	bus to use with this driver
+//		i2c.WithAddress(int):	address to use with this driver, 0x76 by default or 0x77 with SDO high
+//		i2c.WithBME280HumidityOversampling(BMP280Oversampling):	humidity oversampling
+//
+// All of the i2c.WithBMP280... options can be used as well.
+//
+func NewBME280Driver(c Connector, options ...func(Config)) *BME280Driver {
+	b := &BME280Driver{
+		BMP280Driver:    NewBMP280Driver(c),
+		hc:              &bme280HumidityCalibrationCoefficients{},
+		humOversampling: BMP280Oversampling1x,
+	}
+	b.SetName(gobot.DefaultName("BME280"))
+	b.duration = b.MeasurementDuration
+	b.initVariant = b.initHumidity
+
+	for _, option := range options {
+		option(b)
+	}
+
+	b.addHumidityCommand()
+	return b
+}
+
+func (d *BME280Driver) addHumidityCommand() {
+	d.AddCommand("Humidity", func(params map[string]interface{}) interface{} {
+		hum, err := d.Humidity()
+		return map[string]interface{}{"val": hum, "err": err}
+	})
+}
+
+// WithBME280HumidityOversampling option sets the BME280Driver humidity oversampling.
+func WithBME280HumidityOversampling(val BMP280Oversampling) func(Config) {
+	return func(c Config) {
+		d, ok := bme280DriverFromConfig(c)
+		if ok {
+			d.humOversampling = val
+		} else {
+			panic("Trying to set humidity oversampling for non-BME280Driver")
+		}
+	}
+}
+
+// bme280DriverFromConfig returns the BME280Driver an option is applied to,
+// which is the embedded one in case of the BoschEnvDriver.
+func bme280DriverFromConfig(c Config) (*BME280Driver, bool) {
+	switch d := c.(type) {
+	case *BME280Driver:
+		return d, true
+	case *BoschEnvDriver:
+		return d.BME280Driver, true
+	}
+	return nil, false
+}
+
+// Clone returns a new, unstarted driver with the same connector and
+// configuration, to which the given options are then applied.
+func (d *BME280Driver) Clone(options ...func(Config)) *BME280Driver {
+	c := d.clone()
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func (d *BME280Driver) clone() *BME280Driver {
+	hc := *d.hc
+	c := &BME280Driver{
+		BMP280Driver:    d.BMP280Driver.clone(),
+		hc:              &hc,
+		humOversampling: d.humOversampling,
+	}
+	c.SetName(gobot.DefaultName("BME280"))
+	c.duration = c.MeasurementDuration
+	c.initVariant = c.initHumidity
+	c.addHumidityCommand()
+	return c
+}
+
+// Start initializes the BME280 and loads the calibration coefficients.
+func (d *BME280Driver) Start() (err error) {
+	if d.started {
+		return nil
+	}
+	if err = d.start(); err != nil {
+		return err
+	}
+	if err = d.initHumidity(); err != nil {
+		return err
+	}
+	if err = d.discard(d.OutputDataRate()); err != nil {
+		return err
+	}
+	d.health.set(nil)
+	d.poll()
+	d.started = true
+	return nil
+}
+
+// Reset performs a soft reset of the device, and then reloads the
+// calibration coefficients and the configuration.
+func (d *BME280Driver) Reset() (err error) {
+	if err = d.BMP280Driver.Reset(); err != nil {
+		return err
+	}
+	return d.initHumidity()
+}
+
+// MeasurementDuration returns the maximum duration of a measurement with the
+// configured oversampling, as given by the datasheet:
+//
+//		1.25ms + 2.3ms * osrs_t + (2.3ms * osrs_p + 0.575ms) + (2.3ms * osrs_h + 0.575ms)
+//
+// where the terms of a skipped measurement are left out.
+func (d *BME280Driver) MeasurementDuration() time.Duration {
+	return bmp280MeasurementDuration(d.tempOversampling, d.pressOversampling, d.humOversampling)
+}
+
+// CompensationModel returns the algorithm used to compensate the readings,
+// as "bme280-float-bosch-rev1.1". The humidity is always compensated with the
+// floating-point algorithm.
+func (d *BME280Driver) CompensationModel() string {
+	return bmp280CompensationModel("bme280", "rev1.1", d.integerCompensation)
+}
+
+// OutputDataRate returns the number of measurements per second in normal
+// mode with the configured settings, including the humidity.
+func (d *BME280Driver) OutputDataRate() float64 {
+	return bmp280OutputDataRate(d.duration(), d.standby.duration(true))
+}
+
+// Validate returns ErrBMP280InvalidSettings, describing the conflicting
+// settings, if the device is in normal mode and its output data rate with
+// the configured oversampling, including the humidity, and standby time is
+// below the given target rate, in Hz.
+func (d *BME280Driver) Validate(rate float64) error {
+	return d.validate(rate, d.standby.duration(true), fmt.Sprintf("temperature oversampling x%d, pressure oversampling x%d and humidity oversampling x%d",
+		d.tempOversampling.ratio(), d.pressOversampling.ratio(), d.humOversampling.ratio()))
+}
+
+// Humidity returns the current relative humidity, in percent.
+func (d *BME280Driver) Humidity() (hum float32, err error) {
+	var h float64
+	if _, h, err = d.temperatureAndHumidity(); err != nil {
+		return 0.0, err
+	}
+	return float32(h), nil
+}
+
+// HumidityWithContext is like Humidity, but returns ctx.Err() as soon
+// as the context is done.
+func (d *BME280Driver) HumidityWithContext(ctx context.Context) (hum float32, err error) {
+	return bmp280WithContext(ctx, d.Humidity)
+}
+
+// Read returns the temperature, pressure, humidity and altitude of a single sample.
+func (d *BME280Driver) Read() (m BMP280Measurement, err error) {
+	if err = d.checkPressureEnabled(); err != nil {
+		return m, err
+	}
+	var rawT, rawP, rawH int32
+	if rawT, rawP, rawH, err = d.rawTempPressHum(); err != nil {
+		return m, err
+	}
+	var tFine int32
+	if m, tFine, err = d.measurement(rawT, rawP); err != nil {
+		return m, err
+	}
+	hum := d.calculateHumidity(rawH, tFine)
+	d.observe(BME280MetricHumidity, hum)
+	m.Humidity = float32(hum)
+	m.hasHumidity = true
+	return m, nil
+}
+
+// DewPoint returns the current dew point, in celsius degrees, computed from
+// the temperature and relative humidity of the same sample using the
+// Magnus-Tetens approximation:
+//
+//		gamma = ln(RH / 100) + b * T / (c + T)
+//		dew point = c * gamma / (b - gamma)
+//
+// with b = 17.62 and c = 243.12, valid for temperatures from -45 to 60 celsius degrees.
+func (d *BME280Driver) DewPoint() (dew float32, err error) {
+	var t, h float64
+	if t, h, err = d.temperatureAndHumidity(); err != nil {
+		return 0.0, err
+	}
+	if h == 0 {
+		return 0.0, errors.New("BME280: dew point is undefined at 0% relative humidity")
+	}
+	gamma := math.Log(h/100.0) + bme280MagnusB*t/(bme280MagnusC+t)
+	return float32(bme280MagnusC * gamma / (bme280MagnusB - gamma)), nil
+}
+
+// HeatIndex returns the current heat index, the apparent temperature felt by
+// humans, in the configured unit. It is computed from the temperature and
+// relative humidity of the same sample with the NWS algorithm: the simple
+// Steadman formula, averaged with the temperature, if it is below 80
+// fahrenheit degrees, and else the Rothfusz regression with its adjustments
+// for low and high humidity.
+// See https://www.wpc.ncep.noaa.gov/html/heatindex_equation.shtml
+func (d *BME280Driver) HeatIndex() (hi float32, err error) {
+	var t, h float64
+	if t, h, err = d.temperatureAndHumidity(); err != nil {
+		return 0.0, err
+	}
+	f := bme280HeatIndex(TemperatureUnitFahrenheit.fromCelsius(t), h)
+	return d.temperatureValue(TemperatureUnitFahrenheit.toCelsius(f)), nil
+}
+
+// bme280HeatIndex returns the heat index, in fahrenheit degrees, of the
+// temperature, in fahrenheit degrees, and the relative humidity, in percent.
+func bme280HeatIndex(t float64, rh float64) float64 {
+	// the simple formula, already averaged with the temperature.
+	simple := 0.5 * (t + 61.0 + (t-68.0)*1.2 + rh*0.094)
+	if simple < 80.0 {
+		return simple
+	}
+
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	if rh < 13.0 && t >= 80.0 && t <= 112.0 {
+		hi -= (13.0 - rh) / 4.0 * math.Sqrt((17.0-math.Abs(t-95.0))/17.0)
+	} else if rh > 85.0 && t >= 80.0 && t <= 87.0 {
+		hi += (rh - 85.0) / 10.0 * (87.0 - t) / 5.0
+	}
+	return hi
+}
+
+// temperatureAndHumidity returns the temperature, in celsius degrees, and the
+// relative humidity, in percent, of the same sample.
+func (d *BME280Driver) temperatureAndHumidity() (temp float64, hum float64, err error) {
+	var rawT, rawH int32
+	if rawT, _, rawH, err = d.rawTempPressHum(); err != nil {
+		return 0.0, 0.0, err
+	}
+	temp, tFine := d.calculateTemp(rawT)
+	hum = d.calculateHumidity(rawH, tFine)
+	d.observe(BMP280MetricTemperature, temp)
+	d.observe(BME280MetricHumidity, hum)
+	return temp, hum, nil
+}
+
+// initHumidity verifies the device is a BME280, reads the humidity calibration
+// coefficients and configures the humidity oversampling.
+func (d *BME280Driver) initHumidity() (err error) {
+	d.mutex.Lock()
+	chipID := d.chipID
+	d.mutex.Unlock()
+	if chipID != bme280ChipID {
+		return fmt.Errorf("%w 0x%02X, not a BME280", ErrBMP280BadChipID, chipID)
+	}
+
+	var h1, coefficients []byte
+	if h1, err = d.read(bme280RegisterCalibDigH1, 1); err != nil {
+		return err
+	}
+	if coefficients, err = d.read(bme280RegisterCalibDigH2, 7); err != nil {
+		return err
+	}
+	hc := bme280HumidityCalibrationCoefficients{h1: h1[0]}
+	buf := bytes.NewBuffer(coefficients)
+	binary.Read(buf, binary.LittleEndian, &hc.h2)
+	binary.Read(buf, binary.LittleEndian, &hc.h3)
+	// h4 and h5 are 12 bit values sharing the nibbles of 0xE5.
+	hc.h4 = int16(int8(coefficients[3]))<<4 | int16(coefficients[4]&0x0f)
+	hc.h5 = int16(int8(coefficients[5]))<<4 | int16(coefficients[4]>>4)
+	hc.h6 = int8(coefficients[6])
+	d.mutex.Lock()
+	*d.hc = hc
+	d.mutex.Unlock()
+
+	if err = d.write(bme280RegisterControlHumidity, byte(d.humOversampling)); err != nil {
+		return err
+	}
+	// changes to ctrl_hum only become effective after writing ctrl_meas.
+	return d.enterPowerMode()
+}
+
+func (d *BME280Driver) rawTempPressHum() (temp int32, press int32, hum int32, err error) {
+	d.scratch.mutex.Lock()
+	defer d.scratch.mutex.Unlock()
+	data := d.scratch.data[:8]
+	if err = d.readData(bmp280RegisterPressureData, data); err != nil {
+		return 0, 0, 0, err
+	}
+	press = int32(data[0])<<12 | int32(data[1])<<4 | int32(data[2])>>4
+	temp = int32(data[3])<<12 | int32(data[4])<<4 | int32(data[5])>>4
+	hum = int32(data[6])<<8 | int32(data[7])
+	if err = d.checkRaw(temp, press); err != nil {
+		return 0, 0, 0, err
+	}
+	if hum == bme280RawHumidityReset {
+		return 0, 0, 0, d.noMeasurement("humidity", d.humOversampling)
+	}
+	return
+}
+
+func (d *BME280Driver) calculateHumidity(rawHum int32, tFine int32) float64 {
+	d.mutex.Lock()
+	hc := *d.hc
+	d.mutex.Unlock()
+
+	h := float64(tFine) - 76800.0
+	h = (float64(rawHum) - (float64(hc.h4)*64.0 + float64(hc.h5)/16384.0*h)) *
+		(float64(hc.h2) / 65536.0 * (1.0 + float64(hc.h6)/67108864.0*h*(1.0+float64(hc.h3)/67108864.0*h)))
+	h = h * (1.0 - float64(hc.h1)*h/524288.0)
+
+	if h > 100.0 {
+		return 100.0
+	}
+	if h < 0.0 {
+		return 0.0
+	}
+	return h
+}
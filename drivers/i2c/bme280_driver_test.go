@@ -0,0 +1,410 @@
+package i2c
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/gobottest"
+)
+
+var _ gobot.Driver = (*BME280Driver)(nil)
+
+// --------- HELPERS
+func initTestBME280Driver() (driver *BME280Driver) {
+	driver, _ = initTestBME280DriverWithStubbedAdaptor()
+	return
+}
+
+func initTestBME280DriverWithStubbedAdaptor() (*BME280Driver, *i2cTestAdaptor) {
+	adaptor := newI2cTestAdaptor()
+	return NewBME280Driver(adaptor), adaptor
+}
+
+// bme280TestReadImpl extends the BMP280 read stub with the humidity registers.
+func bme280TestReadImpl(adaptor *i2cTestAdaptor) func([]byte) (int, error) {
+	bmp280Impl := bmp280TestReadImpl(adaptor)
+	return func(b []byte) (int, error) {
+		buf := new(bytes.Buffer)
+		switch adaptor.written[len(adaptor.written)-1] {
+		case bmp280RegisterChipID:
+			buf.WriteByte(bme280ChipID)
+		case bme280RegisterCalibDigH1:
+			buf.WriteByte(75)
+		case bme280RegisterCalibDigH2:
+			buf.Write([]byte{0x6a, 0x01, 0x00, 0x13, 0x29, 0x03, 0x1e})
+		case bmp280RegisterPressureData:
+			buf.Write([]byte{0x65, 0x5a, 0xc0, 0x7e, 0xed, 0x00, 0x6a, 0x2b})
+		default:
+			return bmp280Impl(b)
+		}
+		return copy(b, buf.Bytes()), nil
+	}
+}
+
+// --------- TESTS
+
+func TestNewBME280Driver(t *testing.T) {
+	// Does it return a pointer to an instance of BME280Driver?
+	var bme280 interface{} = NewBME280Driver(newI2cTestAdaptor())
+	_, ok := bme280.(*BME280Driver)
+	if !ok {
+		t.Errorf("NewBME280Driver() should have returned a *BME280Driver")
+	}
+}
+
+func TestBME280Driver(t *testing.T) {
+	bme280 := initTestBME280Driver()
+	gobottest.Refute(t, bme280.Connection(), nil)
+	gobottest.Assert(t, bme280.Name()[:6], "BME280")
+}
+
+func TestBME280DriverStart(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	gobottest.Assert(t, bme280.LastError(), ErrBMP280NotStarted)
+	gobottest.Assert(t, bme280.Start(), nil)
+	gobottest.Assert(t, bme280.Healthy(), true)
+	gobottest.Assert(t, *bme280.hc, bme280HumidityCalibrationCoefficients{
+		h1: 75, h2: 362, h3: 0, h4: 313, h5: 50, h6: 30,
+	})
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-4:], []byte{
+		bme280RegisterControlHumidity, 0x01,
+		bmp280RegisterControl, 0x27,
+	})
+}
+
+func TestBME280DriverStartTwice(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	gobottest.Assert(t, bme280.Start(), nil)
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bme280.Start(), nil)
+	gobottest.Assert(t, len(adaptor.written), 0)
+}
+
+func TestBME280DriverStartNotBME280(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	err := bme280.Start()
+	gobottest.Assert(t, err.Error(), "BMP280: unexpected chip id 0x58, not a BME280")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280BadChipID), true)
+}
+
+func TestBME280DriverMeasurements(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	hum, err := bme280.Humidity()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hum, float32(39.275326))
+	temp, err := bme280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+}
+
+func TestBME280DriverRead(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	m, err := bme280.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, m.Temperature, float32(25.082478))
+	gobottest.Assert(t, m.Pressure, float32(100653.26))
+	gobottest.Assert(t, m.Humidity, float32(39.275326))
+	gobottest.Assert(t, m.Altitude, float32(56.07641))
+	gobottest.Refute(t, m.Time.IsZero(), true)
+}
+
+func TestBME280DriverGetChipID(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	id, err := bme280.GetChipID()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, id, byte(0x60))
+}
+
+func TestBME280DriverString(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	bme280.SetName("bme")
+	gobottest.Assert(t, bme280.String(), "bme (BME280, bus 0, address 0x76)")
+}
+
+func TestBME280DriverMeasurementDuration(t *testing.T) {
+	bme280 := NewBME280Driver(newI2cTestAdaptor())
+	gobottest.Assert(t, bme280.MeasurementDuration(), 9300*time.Microsecond)
+	gobottest.Assert(t, bme280.duration(), 9300*time.Microsecond)
+
+	bme280 = NewBME280Driver(newI2cTestAdaptor(), WithBME280HumidityOversampling(BMP280OversamplingSkip))
+	gobottest.Assert(t, bme280.MeasurementDuration(), 6425*time.Microsecond)
+}
+
+func TestBME280MeasurementJSON(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	m, err := bme280.Read()
+	gobottest.Assert(t, err, nil)
+	m.Time = time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(m)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, string(data),
+		`{"temperature_c":25.082478,"pressure_pa":100653.26,"humidity_pct":39.275326,"altitude_m":56.07641,"timestamp":"2017-05-01T12:00:00Z"}`)
+
+	var decoded BMP280Measurement
+	gobottest.Assert(t, json.Unmarshal(data, &decoded), nil)
+	gobottest.Assert(t, decoded.Humidity, float32(39.275326))
+	again, _ := json.Marshal(decoded)
+	gobottest.Assert(t, string(again), string(data))
+}
+
+func TestBME280DriverReset(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bme280.Reset(), nil)
+	gobottest.Assert(t, adaptor.written[:2], []byte{bmp280RegisterReset, bmp280CmdReset})
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-4:len(adaptor.written)-2], []byte{bme280RegisterControlHumidity, 0x01})
+}
+
+func TestBME280DriverSetAddress(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	WithBME280HumidityOversampling(BMP280Oversampling4x)(bme280)
+	gobottest.Assert(t, bme280.Start(), nil)
+
+	// the humidity of the new device is initialized as well.
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bme280.SetAddress(0x77), nil)
+	gobottest.Assert(t, bytes.Contains(adaptor.written, []byte{bme280RegisterCalibDigH1}), true)
+	gobottest.Assert(t, bytes.Contains(adaptor.written, []byte{bme280RegisterCalibDigH2}), true)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-4:len(adaptor.written)-2], []byte{bme280RegisterControlHumidity, 0x03})
+
+	clone := bme280.Clone()
+	gobottest.Assert(t, clone.Start(), nil)
+	adaptor.written = []byte{}
+	gobottest.Assert(t, clone.SetBus(1), nil)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-4:len(adaptor.written)-2], []byte{bme280RegisterControlHumidity, 0x03})
+}
+
+func TestBME280DriverConcurrentSetAddress(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280 := NewBME280Driver(&bmp280TestConnector{i2cTestAdaptor: adaptor})
+	gobottest.Assert(t, bme280.Start(), nil)
+
+	// the chip id is reloaded by Reset while the humidity is initialized.
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 3; i++ {
+			if err := bme280.Reset(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for _, address := range []int{0x77, 0x76, 0x77} {
+		gobottest.Assert(t, bme280.SetAddress(address), nil)
+	}
+	gobottest.Assert(t, <-done, nil)
+	hum, err := bme280.Humidity()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hum, float32(39.275326))
+}
+
+func TestBME280DriverHumidityWithContext(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	gobottest.Assert(t, bme280.Start(), nil)
+
+	hum, err := bme280.HumidityWithContext(context.Background())
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hum, float32(39.275326))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = bme280.HumidityWithContext(ctx)
+	gobottest.Assert(t, err, context.Canceled)
+}
+
+func TestBME280DriverOptions(t *testing.T) {
+	b := NewBME280Driver(newI2cTestAdaptor(), WithBus(2),
+		WithBME280HumidityOversampling(BMP280Oversampling4x),
+		WithBMP280IIRFilter(BMP280Filter4))
+	gobottest.Assert(t, b.GetBusOrDefault(1), 2)
+	gobottest.Assert(t, b.humOversampling, BMP280Oversampling4x)
+	gobottest.Assert(t, b.filter, BMP280Filter4)
+}
+
+func TestBME280DriverValidate(t *testing.T) {
+	bme280 := NewBME280Driver(newI2cTestAdaptor(), WithBMP280StandbyTime(BMP280Standby2000ms),
+		WithBMP280TemperatureOversampling(BMP280Oversampling16x),
+		WithBMP280PressureOversampling(BMP280Oversampling16x),
+		WithBME280HumidityOversampling(BMP280Oversampling16x))
+	err := bme280.Validate(10)
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidSettings), true)
+	gobottest.Assert(t, err.Error(), "BMP280: invalid settings, measurements of up to 112.8ms with temperature "+
+		"oversampling x16, pressure oversampling x16 and humidity oversampling x16 and the standby time of 10ms give 8.1 Hz instead of 10.0 Hz")
+	gobottest.Assert(t, bme280.Validate(8), nil)
+
+	WithBMP280StandbyTime(BMP280Standby125ms)(bme280)
+	gobottest.Assert(t, bme280.Validate(4), nil)
+	gobottest.Assert(t, math.Round(bme280.OutputDataRate()*1000)/1000, 4.205)
+}
+
+func TestBME280DriverCompensationModel(t *testing.T) {
+	gobottest.Assert(t, initTestBME280Driver().CompensationModel(), "bme280-float-bosch-rev1.1")
+	bme280 := NewBME280Driver(newI2cTestAdaptor(), WithBMP280IntegerCompensation())
+	gobottest.Assert(t, bme280.CompensationModel(), "bme280-int-bosch-rev1.1")
+}
+
+func TestBME280DriverClone(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	WithBME280HumidityOversampling(BMP280Oversampling8x)(bme280)
+	gobottest.Assert(t, bme280.Start(), nil)
+
+	clone := bme280.Clone(WithAddress(0x77))
+	gobottest.Assert(t, clone.Name()[:6], "BME280")
+	gobottest.Assert(t, clone.GetAddressOrDefault(bmp280Address), 0x77)
+	gobottest.Assert(t, clone.humOversampling, BMP280Oversampling8x)
+	gobottest.Assert(t, clone.hc != bme280.hc, true)
+	gobottest.Assert(t, clone.MeasurementDuration(), bme280.MeasurementDuration())
+
+	gobottest.Assert(t, clone.Start(), nil)
+	ret := clone.Command("Humidity")(map[string]interface{}{}).(map[string]interface{})
+	gobottest.Assert(t, ret["val"], float32(39.275326))
+}
+
+func TestBME280DriverCommands(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+
+	result := bme280.Command("Humidity")(map[string]interface{}{})
+	gobottest.Assert(t, result.(map[string]interface{})["val"], float32(39.275326))
+	gobottest.Assert(t, result.(map[string]interface{})["err"], nil)
+}
+
+func TestBME280DriverDewPoint(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	dew, err := bme280.DewPoint()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, dew, float32(10.256734))
+
+	adaptor.i2cReadImpl = func([]byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	_, err = bme280.DewPoint()
+	gobottest.Assert(t, err, errors.New("read error"))
+}
+
+func TestBME280DriverHeatIndex(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	hi, err := bme280.HeatIndex()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hi, float32(24.671804))
+
+	WithBMP280TemperatureUnit(TemperatureUnitFahrenheit)(bme280)
+	hi, err = bme280.HeatIndex()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hi, float32(76.40925))
+}
+
+func TestBME280HeatIndex(t *testing.T) {
+	var tests = []struct {
+		temp, hum, hi float64
+	}{
+		{temp: 70, hum: 50, hi: 69.05},
+		// the simple formula gives just below and above 80 fahrenheit degrees.
+		{temp: 80, hum: 40, hi: 79.58},
+		{temp: 80, hum: 60, hi: 81.81},
+		// the simple formula gives 80.83 below 80 fahrenheit degrees, and
+		// 79.74 above, so the regression is used only in the first case.
+		{temp: 79, hum: 90, hi: 82.98},
+		{temp: 81, hum: 20, hi: 79.74},
+		{temp: 90, hum: 50, hi: 94.6},
+		// low and high humidity adjustments.
+		{temp: 100, hum: 10, hi: 94.12},
+		{temp: 85, hum: 90, hi: 101.78},
+	}
+	for _, tt := range tests {
+		hi := bme280HeatIndex(tt.temp, tt.hum)
+		gobottest.Assert(t, math.Round(hi*100)/100, tt.hi)
+	}
+}
+
+func TestBME280DriverNoHumidityMeasurement(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	readImpl := bme280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = readImpl
+	bme280.Start()
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterPressureData {
+			return copy(b, []byte{0x65, 0x5a, 0xc0, 0x7e, 0xed, 0x00, 0x80, 0x00}), nil
+		}
+		return readImpl(b)
+	}
+	_, err := bme280.Humidity()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280NoMeasurement), true)
+	gobottest.Assert(t, err.Error(), "BMP280: no measurement available (no measurement completed yet)")
+}
+
+func TestBME280DriverMetricSink(t *testing.T) {
+	metrics := map[string]float64{}
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	WithBMP280MetricSink(func(name string, value float64) {
+		metrics[name] = value
+	})(bme280)
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	bme280.Start()
+	_, err := bme280.Humidity()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, float32(metrics[BME280MetricHumidity]), float32(39.275326))
+	gobottest.Assert(t, float32(metrics[BMP280MetricTemperature]), float32(25.082478))
+
+	metrics = map[string]float64{}
+	_, err = bme280.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, len(metrics), 3)
+}
+
+func TestBME280DriverPressureSkipped(t *testing.T) {
+	bme280, adaptor := initTestBME280DriverWithStubbedAdaptor()
+	readImpl := bme280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = readImpl
+	WithBMP280PressureOversampling(BMP280OversamplingSkip)(bme280)
+	bme280.Start()
+	// the skipped pressure reads as the reset value.
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterPressureData {
+			return copy(b, []byte{0x80, 0x00, 0x00, 0x7e, 0xed, 0x00, 0x6a, 0x2b}), nil
+		}
+		return readImpl(b)
+	}
+	hum, err := bme280.Humidity()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hum, float32(39.275326))
+	_, err = bme280.Read()
+	gobottest.Assert(t, err.Error(), "BMP280: no measurement available (pressure measurement skipped)")
+}
+
+func TestBME280DriverNotStarted(t *testing.T) {
+	bme280 := initTestBME280Driver()
+	_, err := bme280.Humidity()
+	gobottest.Assert(t, err, errors.New("BMP280: driver not started"))
+}
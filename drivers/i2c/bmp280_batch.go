@@ -0,0 +1,77 @@
+package i2c
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BMP280Reader is implemented by the BMP280Driver, BME280Driver and
+// BoschEnvDriver, to be read together with ReadBMP280Drivers.
+type BMP280Reader interface {
+	Name() string
+	Read() (BMP280Measurement, error)
+}
+
+// BMP280ReadErrors are the errors of the drivers that failed in
+// ReadBMP280Drivers, by driver name.
+type BMP280ReadErrors map[string]error
+
+func (e BMP280ReadErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = name + ": " + e[name].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ReadBMP280Drivers reads the given drivers concurrently, with at most the
+// given number of reads in progress, or all of them at once if it is not
+// positive. It returns the measurements of the drivers by name, and
+// BMP280ReadErrors if any of the reads failed, in which case the
+// measurements of the other drivers are returned as well. The names of
+// the drivers must be unique.
+func ReadBMP280Drivers(drivers []BMP280Reader, workers int) (map[string]BMP280Measurement, error) {
+	if workers <= 0 || workers > len(drivers) {
+		workers = len(drivers)
+	}
+
+	var mutex sync.Mutex
+	measurements := make(map[string]BMP280Measurement, len(drivers))
+	errs := BMP280ReadErrors{}
+
+	var wg sync.WaitGroup
+	queue := make(chan BMP280Reader)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range queue {
+				m, err := d.Read()
+				mutex.Lock()
+				if err != nil {
+					errs[d.Name()] = err
+				} else {
+					measurements[d.Name()] = m
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, d := range drivers {
+		queue <- d
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return measurements, errs
+	}
+	return measurements, nil
+}
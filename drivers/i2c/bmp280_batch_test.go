@@ -0,0 +1,81 @@
+package i2c
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+var _ BMP280Reader = (*BMP280Driver)(nil)
+var _ BMP280Reader = (*BME280Driver)(nil)
+var _ BMP280Reader = (*BoschEnvDriver)(nil)
+
+// bmp280TestSlowTransport counts the concurrent reads of the data registers.
+type bmp280TestSlowTransport struct {
+	*BMP280RegisterMap
+	mutex   *sync.Mutex
+	current *int
+	max     *int
+}
+
+func (t bmp280TestSlowTransport) ReadRegisters(reg byte, data []byte) error {
+	if reg == bmp280RegisterPressureData {
+		t.mutex.Lock()
+		*t.current++
+		if *t.current > *t.max {
+			*t.max = *t.current
+		}
+		t.mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		t.mutex.Lock()
+		*t.current--
+		t.mutex.Unlock()
+	}
+	return t.BMP280RegisterMap.ReadRegisters(reg, data)
+}
+
+func TestReadBMP280Drivers(t *testing.T) {
+	var mutex sync.Mutex
+	var current, max int
+	var drivers []BMP280Reader
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+		gobottest.Assert(t, err, nil)
+		transport := bmp280TestSlowTransport{BMP280RegisterMap: regs, mutex: &mutex, current: &current, max: &max}
+		d := NewBMP280Driver(nil, WithBMP280Name(name), WithBMP280Transport(transport))
+		gobottest.Assert(t, d.Start(), nil)
+		drivers = append(drivers, d)
+	}
+
+	measurements, err := ReadBMP280Drivers(drivers, 2)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, len(measurements), 5)
+	gobottest.Assert(t, measurements["c"].Temperature, float32(25.082478))
+	gobottest.Assert(t, measurements["c"].Pressure, float32(100653.26))
+	gobottest.Assert(t, max >= 1 && max <= 2, true)
+}
+
+func TestReadBMP280DriversError(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	started := NewBMP280Driver(nil, WithBMP280Name("started"), WithBMP280Transport(regs))
+	gobottest.Assert(t, started.Start(), nil)
+	drivers := []BMP280Reader{
+		started,
+		NewBMP280Driver(nil, WithBMP280Name("b")),
+		NewBME280Driver(nil, WithBMP280Name("a")),
+	}
+
+	measurements, err := ReadBMP280Drivers(drivers, 0)
+	gobottest.Assert(t, err.Error(), "a: BMP280: driver not started; b: BMP280: driver not started")
+	gobottest.Assert(t, err.(BMP280ReadErrors)["b"], ErrBMP280NotStarted)
+	gobottest.Assert(t, len(measurements), 1)
+	gobottest.Assert(t, measurements["started"].Temperature, float32(25.082478))
+
+	measurements, err = ReadBMP280Drivers(nil, 4)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, len(measurements), 0)
+}
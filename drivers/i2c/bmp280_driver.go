@@ -0,0 +1,2907 @@
+package i2c
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot"
+)
+
+const (
+	// bmp280Address is the address of the BMP280 with SDO connected to GND.
+	bmp280Address = 0x76
+	// bmp280AlternateAddress is the address of the BMP280 with SDO connected to VDDIO.
+	bmp280AlternateAddress = 0x77
+)
+
+const (
+	bmp280RegisterStatus       = 0xf3
+	bmp280RegisterControl      = 0xf4
+	bmp280RegisterConfig       = 0xf5
+	bmp280RegisterPressureData = 0xf7
+	bmp280RegisterTempData     = 0xfa
+	bmp280RegisterCalib00      = 0x88
+	bmp280RegisterChipID       = 0xd0
+	bmp280RegisterVersion      = 0xd1
+	bmp280RegisterReset        = 0xe0
+
+	// bmp280CalibrationLength is the size of the calibration block, from
+	// 0x88 to 0x9F: the 12 coefficients dig_T1 to dig_P9, of 2 bytes each.
+	// 0xA0 is reserved, and 0xA1 holds dig_H1 on the BME280, read apart with
+	// the other humidity coefficients, so neither is part of the block.
+	bmp280CalibrationLength = 24
+
+	bmp280CmdReset = 0xb6
+
+	bmp280StatusMeasuring = 0x08
+	bmp280StatusImUpdate  = 0x01
+
+	// bmp280StatusPollInterval is the interval at which the status register
+	// is read while waiting for a measurement to complete.
+	bmp280StatusPollInterval = time.Millisecond
+
+	// bmp280StartupTime is the time needed by the device after power on or reset.
+	bmp280StartupTime = 2 * time.Millisecond
+
+	bmp280ChipID = 0x58
+	bme280ChipID = 0x60
+
+	// bmp280RawReset is the reset value of the 20 bit temperature and pressure
+	// data, read until a first measurement is done.
+	bmp280RawReset = 0x80000
+
+	// bmp280SeaLevelPressure is the standard atmosphere at sea level, in pascals.
+	bmp280SeaLevelPressure = 101325.0
+
+	// bmp280MinPressure and bmp280MaxPressure are the operating range
+	// of the sensor, in pascals.
+	bmp280MinPressure = 30000.0
+	bmp280MaxPressure = 110000.0
+
+	// bmp280MinTemperature and bmp280MaxTemperature are the operating range
+	// of the sensor, in celsius degrees.
+	bmp280MinTemperature = -40.0
+	bmp280MaxTemperature = 85.0
+)
+
+// bmp280MuxMutex serializes the transactions of all the drivers behind a
+// multiplexer, so that the channel selection and the transaction are atomic.
+var bmp280MuxMutex sync.Mutex
+
+// The errors of the BMP280Driver and BME280Driver, possibly wrapped with
+// more details, so they are to be checked with errors.Is. Other errors come
+// from the bus, as a BMP280BusError if the connection tells a missing device
+// from a bus error.
+var (
+	// ErrBMP280NotStarted is returned when accessing the device before Start.
+	ErrBMP280NotStarted = errors.New("BMP280: driver not started")
+	// ErrBMP280ShortRead is returned when fewer bytes than requested are read.
+	ErrBMP280ShortRead = errors.New("BMP280: short read")
+	// ErrBMP280BadChipID is returned when the device is not of the expected type.
+	ErrBMP280BadChipID = errors.New("BMP280: unexpected chip id")
+	// ErrBMP280MeasurementTimeout is returned when a measurement does not complete in time.
+	ErrBMP280MeasurementTimeout = errors.New("BMP280: measurement timed out")
+	// ErrBMP280InvalidCalibration is returned when the calibration data can not be used.
+	ErrBMP280InvalidCalibration = errors.New("BMP280: invalid calibration data, check wiring")
+	// ErrBMP280OutOfRange is returned when a measurement is outside the operating range of the sensor.
+	ErrBMP280OutOfRange = errors.New("BMP280: measurement out of the valid range")
+	// ErrBMP280WriteVerification is returned when a register does not read back as written.
+	ErrBMP280WriteVerification = errors.New("BMP280: register write did not take effect")
+	// ErrBMP280NoMeasurement is returned when the data registers hold their reset value.
+	ErrBMP280NoMeasurement = errors.New("BMP280: no measurement available")
+	// ErrBMP280InvalidSettings is returned when settings conflict with each other.
+	ErrBMP280InvalidSettings = errors.New("BMP280: invalid settings")
+	// ErrBMP280TransactionTimeout is returned when a bus transaction exceeds the configured timeout.
+	ErrBMP280TransactionTimeout = errors.New("BMP280: bus transaction timed out")
+	// ErrBMP280NeedsReset is returned after a transaction timeout, until the driver is Reset.
+	ErrBMP280NeedsReset = errors.New("BMP280: a bus transaction timed out, Reset is needed")
+	// ErrBMP280InvalidAddress is returned when the address is not a valid 7 bit i2c address.
+	ErrBMP280InvalidAddress = errors.New("BMP280: invalid i2c address")
+	// ErrBMP280CorruptFrame is returned when the data registers read twice as
+	// an impossible frame, e.g. shifted by a byte after a clock stretch.
+	ErrBMP280CorruptFrame = errors.New("BMP280: corrupt data frame")
+)
+
+const (
+	// BMP280TemperatureEvent is emitted with the temperature on every poll.
+	BMP280TemperatureEvent = "temperature"
+
+	// BMP280PressureEvent is emitted with the pressure on every poll.
+	BMP280PressureEvent = "pressure"
+
+	// BMP280ReadyEvent is emitted with the completion time of a conversion
+	// started by Trigger.
+	BMP280ReadyEvent = "ready"
+)
+
+const (
+	// BMP280MetricTemperature is the metric of the temperatures, see WithBMP280MetricSink.
+	BMP280MetricTemperature = "bmp280_temperature_celsius"
+
+	// BMP280MetricPressure is the metric of the pressures, see WithBMP280MetricSink.
+	BMP280MetricPressure = "bmp280_pressure_pascals"
+
+	// BME280MetricHumidity is the metric of the humidities, see WithBMP280MetricSink.
+	BME280MetricHumidity = "bme280_humidity_percent"
+)
+
+const (
+	// BMP280PowerModeSleep performs no measurements, all registers remain accessible.
+	BMP280PowerModeSleep BMP280PowerMode = 0x00
+	// BMP280PowerModeForced performs a single measurement and returns to sleep mode.
+	BMP280PowerModeForced BMP280PowerMode = 0x01
+	// BMP280PowerModeNormal continuously cycles between measurement and standby periods.
+	BMP280PowerModeNormal BMP280PowerMode = 0x03
+)
+
+// BMP280PowerMode is the power mode of the BMP280, as set in the ctrl_meas register.
+type BMP280PowerMode uint8
+
+const (
+	// BMP280OversamplingSkip skips the measurement.
+	BMP280OversamplingSkip BMP280Oversampling = iota
+	// BMP280Oversampling1x takes a single sample per measurement.
+	BMP280Oversampling1x
+	// BMP280Oversampling2x averages 2 samples per measurement.
+	BMP280Oversampling2x
+	// BMP280Oversampling4x averages 4 samples per measurement.
+	BMP280Oversampling4x
+	// BMP280Oversampling8x averages 8 samples per measurement.
+	BMP280Oversampling8x
+	// BMP280Oversampling16x averages 16 samples per measurement.
+	BMP280Oversampling16x
+)
+
+// BMP280Oversampling is the oversampling ratio of the temperature or pressure measurement.
+type BMP280Oversampling uint8
+
+// ratio returns the number of samples per measurement.
+func (o BMP280Oversampling) ratio() int {
+	if o == BMP280OversamplingSkip {
+		return 0
+	}
+	return 1 << (o - 1)
+}
+
+const (
+	// BMP280FilterOff disables the IIR filter.
+	BMP280FilterOff BMP280FilterCoefficient = iota
+	// BMP280Filter2 sets the IIR filter coefficient to 2.
+	BMP280Filter2
+	// BMP280Filter4 sets the IIR filter coefficient to 4.
+	BMP280Filter4
+	// BMP280Filter8 sets the IIR filter coefficient to 8.
+	BMP280Filter8
+	// BMP280Filter16 sets the IIR filter coefficient to 16.
+	BMP280Filter16
+)
+
+// BMP280FilterCoefficient is the coefficient of the IIR filter, as set in the config register.
+type BMP280FilterCoefficient uint8
+
+const (
+	// BMP280Standby500us sets the normal mode standby time to 0.5ms.
+	BMP280Standby500us BMP280StandbyTime = iota
+	// BMP280Standby62500us sets the normal mode standby time to 62.5ms.
+	BMP280Standby62500us
+	// BMP280Standby125ms sets the normal mode standby time to 125ms.
+	BMP280Standby125ms
+	// BMP280Standby250ms sets the normal mode standby time to 250ms.
+	BMP280Standby250ms
+	// BMP280Standby500ms sets the normal mode standby time to 500ms.
+	BMP280Standby500ms
+	// BMP280Standby1000ms sets the normal mode standby time to 1000ms.
+	BMP280Standby1000ms
+	// BMP280Standby2000ms sets the normal mode standby time to 2000ms, or 10ms on the BME280.
+	BMP280Standby2000ms
+	// BMP280Standby4000ms sets the normal mode standby time to 4000ms, or 20ms on the BME280.
+	BMP280Standby4000ms
+)
+
+// BMP280StandbyTime is the inactive time between two measurements in normal mode,
+// as set in the config register.
+type BMP280StandbyTime uint8
+
+// duration returns the standby time, which differs for the last two
+// values on the BME280.
+func (t BMP280StandbyTime) duration(bme280 bool) time.Duration {
+	switch {
+	case t == BMP280Standby500us:
+		return 500 * time.Microsecond
+	case t == BMP280Standby62500us:
+		return 62500 * time.Microsecond
+	case bme280 && t == BMP280Standby2000ms:
+		return 10 * time.Millisecond
+	case bme280 && t == BMP280Standby4000ms:
+		return 20 * time.Millisecond
+	}
+	return 125 * time.Millisecond << (t - BMP280Standby125ms)
+}
+
+const (
+	// TemperatureUnitCelsius reports temperatures in celsius degrees.
+	TemperatureUnitCelsius TemperatureUnit = iota
+	// TemperatureUnitFahrenheit reports temperatures in fahrenheit degrees.
+	TemperatureUnitFahrenheit
+	// TemperatureUnitKelvin reports temperatures in kelvin.
+	TemperatureUnitKelvin
+)
+
+// TemperatureUnit is the unit in which a driver reports temperatures.
+type TemperatureUnit uint8
+
+// fromCelsius converts a temperature in celsius degrees to the unit.
+func (u TemperatureUnit) fromCelsius(temp float64) float64 {
+	switch u {
+	case TemperatureUnitFahrenheit:
+		return temp*9.0/5.0 + 32.0
+	case TemperatureUnitKelvin:
+		return temp + 273.15
+	}
+	return temp
+}
+
+// toCelsius converts a temperature in the unit to celsius degrees.
+func (u TemperatureUnit) toCelsius(temp float64) float64 {
+	switch u {
+	case TemperatureUnitFahrenheit:
+		return (temp - 32.0) * 5.0 / 9.0
+	case TemperatureUnitKelvin:
+		return temp - 273.15
+	}
+	return temp
+}
+
+const (
+	// PascalsPerHectopascal is the number of pascals in a hectopascal.
+	PascalsPerHectopascal = 100.0
+	// PascalsPerMillimeterOfMercury is the number of pascals in a millimeter of mercury.
+	PascalsPerMillimeterOfMercury = 133.322387415
+	// PascalsPerInchOfMercury is the number of pascals in an inch of mercury.
+	PascalsPerInchOfMercury = 3386.389
+)
+
+const (
+	// PressureUnitPascal reports pressures in pascals.
+	PressureUnitPascal PressureUnit = iota
+	// PressureUnitHectopascal reports pressures in hectopascals.
+	PressureUnitHectopascal
+	// PressureUnitMillimeterOfMercury reports pressures in millimeters of mercury.
+	PressureUnitMillimeterOfMercury
+	// PressureUnitInchOfMercury reports pressures in inches of mercury.
+	PressureUnitInchOfMercury
+)
+
+// PressureUnit is the unit in which a driver reports pressures.
+type PressureUnit uint8
+
+// fromPascal converts a pressure in pascals to the unit.
+func (u PressureUnit) fromPascal(press float64) float64 {
+	switch u {
+	case PressureUnitHectopascal:
+		return press / PascalsPerHectopascal
+	case PressureUnitMillimeterOfMercury:
+		return press / PascalsPerMillimeterOfMercury
+	case PressureUnitInchOfMercury:
+		return press / PascalsPerInchOfMercury
+	}
+	return press
+}
+
+// toPascal converts a pressure in the unit to pascals.
+func (u PressureUnit) toPascal(press float64) float64 {
+	switch u {
+	case PressureUnitHectopascal:
+		return press * PascalsPerHectopascal
+	case PressureUnitMillimeterOfMercury:
+		return press * PascalsPerMillimeterOfMercury
+	case PressureUnitInchOfMercury:
+		return press * PascalsPerInchOfMercury
+	}
+	return press
+}
+
+// Temperature is a temperature in celsius degrees, to be read in any unit.
+type Temperature float64
+
+// Celsius returns the temperature in celsius degrees.
+func (t Temperature) Celsius() float64 {
+	return float64(t)
+}
+
+// Fahrenheit returns the temperature in fahrenheit degrees.
+func (t Temperature) Fahrenheit() float64 {
+	return TemperatureUnitFahrenheit.fromCelsius(float64(t))
+}
+
+// Kelvin returns the temperature in kelvin.
+func (t Temperature) Kelvin() float64 {
+	return TemperatureUnitKelvin.fromCelsius(float64(t))
+}
+
+// In returns the temperature in the given unit.
+func (t Temperature) In(unit TemperatureUnit) float64 {
+	return unit.fromCelsius(float64(t))
+}
+
+// Pressure is a pressure in pascals, to be read in any unit.
+type Pressure float64
+
+// Pa returns the pressure in pascals.
+func (p Pressure) Pa() float64 {
+	return float64(p)
+}
+
+// HPa returns the pressure in hectopascals.
+func (p Pressure) HPa() float64 {
+	return PressureUnitHectopascal.fromPascal(float64(p))
+}
+
+// MmHg returns the pressure in millimeters of mercury.
+func (p Pressure) MmHg() float64 {
+	return PressureUnitMillimeterOfMercury.fromPascal(float64(p))
+}
+
+// InHg returns the pressure in inches of mercury.
+func (p Pressure) InHg() float64 {
+	return PressureUnitInchOfMercury.fromPascal(float64(p))
+}
+
+// In returns the pressure in the given unit.
+func (p Pressure) In(unit PressureUnit) float64 {
+	return unit.fromPascal(float64(p))
+}
+
+var (
+	defaultUnitsMutex   sync.Mutex
+	defaultTempUnit     = TemperatureUnitCelsius
+	defaultPressureUnit = PressureUnitPascal
+)
+
+// SetDefaultUnits sets the units in which drivers created afterwards report
+// temperatures and pressures, unless set by their own options. Drivers
+// already created are not changed.
+func SetDefaultUnits(temp TemperatureUnit, press PressureUnit) {
+	defaultUnitsMutex.Lock()
+	defer defaultUnitsMutex.Unlock()
+
+	defaultTempUnit, defaultPressureUnit = temp, press
+}
+
+// defaultUnits returns the units set by SetDefaultUnits, celsius degrees
+// and pascals by default.
+func defaultUnits() (TemperatureUnit, PressureUnit) {
+	defaultUnitsMutex.Lock()
+	defer defaultUnitsMutex.Unlock()
+
+	return defaultTempUnit, defaultPressureUnit
+}
+
+// BMP280CalibrationCoefficients are the factory calibration coefficients
+// of a BMP280, named after the dig_T1 ... dig_P9 registers of the datasheet.
+type BMP280CalibrationCoefficients struct {
+	T1 uint16
+	T2 int16
+	T3 int16
+	P1 uint16
+	P2 int16
+	P3 int16
+	P4 int16
+	P5 int16
+	P6 int16
+	P7 int16
+	P8 int16
+	P9 int16
+}
+
+// BMP280Measurement is a snapshot of all the values of a single sample.
+// Temperature and Pressure are in the configured units, and Altitude in meters.
+// Humidity, in percent, is only measured by the BME280, and zero otherwise.
+//
+// It is encoded to JSON in fixed units, regardless of the configured ones:
+//
+//		{"temperature_c":25.08,"pressure_pa":100653.26,"humidity_pct":39.28,"altitude_m":56.08,"timestamp":"2006-01-02T15:04:05Z"}
+//
+// where humidity_pct is left out for the BMP280. A decoded measurement, as
+// one built by the caller, has its Temperature in celsius degrees and its
+// Pressure in pascals.
+type BMP280Measurement struct {
+	Temperature float32
+	Pressure    float32
+	Humidity    float32
+	Altitude    float32
+	Time        time.Time
+
+	tempUnit    TemperatureUnit
+	pressUnit   PressureUnit
+	hasHumidity bool
+}
+
+type bmp280MeasurementJSON struct {
+	Temperature float32   `json:"temperature_c"`
+	Pressure    float32   `json:"pressure_pa"`
+	Humidity    *float32  `json:"humidity_pct,omitempty"`
+	Altitude    float32   `json:"altitude_m"`
+	Time        time.Time `json:"timestamp"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m BMP280Measurement) MarshalJSON() ([]byte, error) {
+	j := bmp280MeasurementJSON{
+		Temperature: float32(m.tempUnit.toCelsius(float64(m.Temperature))),
+		Pressure:    float32(m.pressUnit.toPascal(float64(m.Pressure))),
+		Altitude:    m.Altitude,
+		Time:        m.Time,
+	}
+	if m.hasHumidity {
+		j.Humidity = &m.Humidity
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *BMP280Measurement) UnmarshalJSON(data []byte) error {
+	var j bmp280MeasurementJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*m = BMP280Measurement{
+		Temperature: j.Temperature,
+		Pressure:    j.Pressure,
+		Altitude:    j.Altitude,
+		Time:        j.Time,
+	}
+	if j.Humidity != nil {
+		m.Humidity = *j.Humidity
+		m.hasHumidity = true
+	}
+	return nil
+}
+
+// BMP280Settings are the settings of the device, as decoded from the
+// ctrl_meas and config registers.
+type BMP280Settings struct {
+	PowerMode               BMP280PowerMode
+	TemperatureOversampling BMP280Oversampling
+	PressureOversampling    BMP280Oversampling
+	Filter                  BMP280FilterCoefficient
+	StandbyTime             BMP280StandbyTime
+}
+
+// BMP280State is the configuration of a BMP280Driver worth persisting, e.g.
+// a sea level pressure calibrated with SeaLevelPressureFromAltitude. It is
+// returned by the State method and restored with the WithBMP280State option.
+type BMP280State struct {
+	SeaLevelPressure        float32                 `json:"sea_level_pressure_pa"`
+	StationAltitude         float32                 `json:"station_altitude_m"`
+	TemperatureUnit         TemperatureUnit         `json:"temperature_unit"`
+	PressureUnit            PressureUnit            `json:"pressure_unit"`
+	TemperatureOversampling BMP280Oversampling      `json:"temperature_oversampling"`
+	PressureOversampling    BMP280Oversampling      `json:"pressure_oversampling"`
+	Filter                  BMP280FilterCoefficient `json:"filter"`
+	StandbyTime             BMP280StandbyTime       `json:"standby_time"`
+}
+
+// BMP280Driver is the gobot driver for the Bosch pressure sensor BMP280.
+// It is safe for concurrent use by multiple goroutines, except for Start,
+// Halt and the options, which are to be used by a single goroutine.
+// Device datasheet: https://cdn-shop.adafruit.com/datasheets/BST-BMP280-DS001-11.pdf
+type BMP280Driver struct {
+	name      string
+	connector Connector
+	transport BMP280Transport
+	mutex     *sync.Mutex
+	Config
+	gobot.Commander
+	gobot.Eventer
+	halt     chan bool
+	interval time.Duration
+
+	tpc                 *BMP280CalibrationCoefficients
+	seaLevelPressure    float32
+	stationAltitude     float32
+	tempOffset          float64
+	tempGain            float64
+	pressOffset         float64
+	pressGain           float64
+	powerMode           BMP280PowerMode
+	tempOversampling    BMP280Oversampling
+	pressOversampling   BMP280Oversampling
+	filter              BMP280FilterCoefficient
+	standby             BMP280StandbyTime
+	chipID              byte
+	version             byte
+	retries             int
+	retryDelay          time.Duration
+	tempUnit            TemperatureUnit
+	pressUnit           PressureUnit
+	measureTimeout      time.Duration
+	customConnection    Connection
+	customTransport     BMP280Transport
+	integerCompensation bool
+	readDelay           time.Duration
+	started             bool
+	timeout             time.Duration
+	needsReset          bool
+	forcedMode          bool
+	forcedSettle        time.Duration
+	discardSamples      int
+	trace               func(addr byte, dir string, data []byte, err error)
+	metricSink          func(name string, value float64)
+	autoDetect          bool
+	duration            func() time.Duration
+	initVariant         func() error
+	presetCalibration   bool
+	calibrationParser   func(data []byte) (BMP280CalibrationCoefficients, error)
+	roundTo             int
+	verifyWrites        bool
+	tempAverage         *bmp280MovingAverage
+	cache               *bmp280ReadCache
+	pressAverage        *bmp280MovingAverage
+	pressKalman         *bmp280KalmanFilter
+	climb               *bmp280VerticalSpeed
+	pressTrend          *bmp280PressureHistory
+	health              *bmp280Health
+	scratch             *bmp280Scratch
+	conversion          *bmp280Conversion
+	muxAddress          byte
+	muxChannel          int
+	muxConnection       Connection
+}
+
+// NewBMP280Driver creates a new driver with specified i2c interface.
+// Params:
+//		conn Connector - the Adaptor to use with this Driver
+//
+// Optional params:
+//		i2c.WithBus(int):	bus to use with this driver
+//		i2c.WithAddress(int):	address to use with this driver, 0x76 by default or 0x77 with SDO high
+//		i2c.WithBMP280Options(...func(Config)):	apply a set of options at once
+//		i2c.WithBMP280Name(string):	name of the driver, BMP280 with a random suffix by default
+//		i2c.WithBMP280SeaLevelPressure(float32):	sea level pressure in pascals
+//		i2c.WithBMP280StationAltitude(float32):	altitude of the station in meters, for the relative pressure
+//		i2c.WithBMP280State(BMP280State):	restore the sea level pressure, units and settings returned by State
+//		i2c.WithBMP280TemperatureOffset(float32, ...float32):	offset in celsius degrees, and optional gain, of the compensated temperatures
+//		i2c.WithBMP280PressureOffset(float32, ...float32):	offset in pascals, and optional gain, of the compensated pressures
+//		i2c.WithBMP280TemperatureOversampling(BMP280Oversampling):	temperature oversampling
+//		i2c.WithBMP280PressureOversampling(BMP280Oversampling):	pressure oversampling
+//		i2c.WithBMP280IIRFilter(BMP280FilterCoefficient):	IIR filter coefficient
+//		i2c.WithBMP280StandbyTime(BMP280StandbyTime):	standby time between measurements in normal mode
+//		i2c.WithBMP280ReadRetries(int, time.Duration):	retries and initial delay of failed reads
+//		i2c.WithBMP280PollInterval(time.Duration):	interval of the temperature and pressure events
+//		i2c.WithBMP280PressureTrend(time.Duration, float32):	window and threshold in pascals of the polled pressure trend
+//		i2c.WithBMP280TemperatureUnit(TemperatureUnit):	unit of the reported temperatures, see also SetDefaultUnits
+//		i2c.WithBMP280PressureUnit(PressureUnit):	unit of the reported pressures, see also SetDefaultUnits
+//		i2c.WithBMP280RoundTo(int):	decimals of the reported temperatures and pressures
+//		i2c.WithBMP280IntegerCompensation():	compensate with the fixed-point algorithm of the datasheet
+//		i2c.WithBMP280WriteVerification():	read back the settings registers after writing them
+//		i2c.WithBMP280MovingAverage(int):	number of samples of the temperature and pressure moving average
+//		i2c.WithBMP280KalmanFilter(float32, float32):	process and measurement noise variances of the pressure Kalman filter
+//		i2c.WithBMP280MinReadInterval(time.Duration):	reuse the last readings for this long
+//		i2c.WithBMP280DiscardSamples(int):	number of samples discarded on Start while the IIR filter fills
+//		i2c.WithBMP280MuxChannel(byte, int):	address and channel of a TCA9548A multiplexer in front of the device
+//		i2c.WithBMP280Timeout(time.Duration):	limit of every bus transaction, after which a Reset is needed
+//		i2c.WithBMP280MeasurementTimeout(time.Duration):	wait for forced measurements to complete
+//		i2c.WithBMP280Connection(Connection):	connection to use instead of the one of the Connector
+//		i2c.WithBMP280Transport(BMP280Transport):	register access to use instead of an i2c connection
+//		i2c.WithBMP280ReadDelay(time.Duration):	delay between the register address write and the data read
+//		i2c.WithBMP280ForcedMode(...time.Duration):	trigger a forced measurement on every read, optionally with a fixed settle time
+//		i2c.WithBMP280Trace(func(byte, string, []byte, error)):	callback invoked on every register read and write attempt
+//		i2c.WithBMP280MetricSink(func(string, float64)):	callback invoked with every value read
+//		i2c.WithBMP280AddressDetection():	fall back to the alternate address if no device answers
+//		i2c.WithBMP280Calibration(BMP280CalibrationCoefficients):	known calibration coefficients, not read on Start
+//		i2c.WithBMP280CalibrationParser(func([]byte) (BMP280CalibrationCoefficients, error)):	parse the calibration of nonstandard clones
+//
+func NewBMP280Driver(c Connector, options ...func(Config)) *BMP280Driver {
+	b := &BMP280Driver{
+		name:              gobot.DefaultName("BMP280"),
+		connector:         c,
+		mutex:             &sync.Mutex{},
+		Config:            NewConfig(),
+		Commander:         gobot.NewCommander(),
+		Eventer:           gobot.NewEventer(),
+		tpc:               &BMP280CalibrationCoefficients{},
+		seaLevelPressure:  bmp280SeaLevelPressure,
+		tempGain:          1,
+		pressGain:         1,
+		powerMode:         BMP280PowerModeNormal,
+		tempOversampling:  BMP280Oversampling1x,
+		pressOversampling: BMP280Oversampling1x,
+		filter:            BMP280FilterOff,
+		roundTo:           -1,
+		muxChannel:        -1,
+		climb:             &bmp280VerticalSpeed{},
+		health:            &bmp280Health{err: ErrBMP280NotStarted},
+		scratch:           &bmp280Scratch{},
+		conversion:        &bmp280Conversion{},
+	}
+
+	b.tempUnit, b.pressUnit = defaultUnits()
+	b.duration = b.MeasurementDuration
+
+	for _, option := range options {
+		option(b)
+	}
+
+	b.addEventsAndCommands()
+	return b
+}
+
+func (d *BMP280Driver) addEventsAndCommands() {
+	d.AddEvent(BMP280TemperatureEvent)
+	d.AddEvent(BMP280PressureEvent)
+	d.AddEvent(BMP280ReadyEvent)
+	d.AddEvent(Error)
+
+	d.AddCommand("Temperature", func(params map[string]interface{}) interface{} {
+		temp, err := d.Temperature()
+		return map[string]interface{}{"val": temp, "err": err}
+	})
+
+	d.AddCommand("Pressure", func(params map[string]interface{}) interface{} {
+		press, err := d.Pressure()
+		return map[string]interface{}{"val": press, "err": err}
+	})
+
+	d.AddCommand("Altitude", func(params map[string]interface{}) interface{} {
+		alt, err := d.Altitude()
+		return map[string]interface{}{"val": alt, "err": err}
+	})
+}
+
+// WithBMP280Options option applies the given options in order, e.g. to
+// reuse a named set of options:
+//
+//		highAccuracy := i2c.WithBMP280Options(
+//			i2c.WithBMP280PressureOversampling(i2c.BMP280Oversampling16x),
+//			i2c.WithBMP280IIRFilter(i2c.BMP280Filter16),
+//		)
+//		d := i2c.NewBMP280Driver(a, highAccuracy, i2c.WithAddress(0x77))
+//
+// A slice of options can be applied with WithBMP280Options(options...),
+// or passed to the constructor directly.
+func WithBMP280Options(options ...func(Config)) func(Config) {
+	return func(c Config) {
+		for _, option := range options {
+			option(c)
+		}
+	}
+}
+
+// WithBMP280Name option sets the BMP280Driver name.
+func WithBMP280Name(name string) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.SetName(name)
+		} else {
+			panic("Trying to set name for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280SeaLevelPressure option sets the BMP280Driver reference pressure
+// at sea level, in pascals.
+func WithBMP280SeaLevelPressure(press float32) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.seaLevelPressure = press
+		} else {
+			panic("Trying to set sea level pressure for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280StationAltitude option sets the BMP280Driver altitude of the
+// station, in meters, used to reduce the pressure to sea level.
+func WithBMP280StationAltitude(alt float32) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.stationAltitude = alt
+		} else {
+			panic("Trying to set station altitude for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280State option restores the BMP280Driver configuration returned
+// by State. It overrides the options applied before it.
+func WithBMP280State(state BMP280State) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.seaLevelPressure = state.SeaLevelPressure
+			d.stationAltitude = state.StationAltitude
+			d.tempUnit = state.TemperatureUnit
+			d.pressUnit = state.PressureUnit
+			d.tempOversampling = state.TemperatureOversampling
+			d.pressOversampling = state.PressureOversampling
+			d.filter = state.Filter
+			d.standby = state.StandbyTime
+		} else {
+			panic("Trying to set state for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280TemperatureOffset option corrects the compensated temperatures of
+// the BMP280Driver, in celsius degrees, e.g. after calibrating the device
+// against a reference: the temperature is multiplied by the gain, 1 if not
+// given, and the offset added. The fine temperature used to compensate the
+// pressure and the humidity is not corrected, and neither is
+// FixedTemperatureAndPressure.
+func WithBMP280TemperatureOffset(offset float32, gain ...float32) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.tempOffset, d.tempGain = float64(offset), 1
+			if len(gain) > 0 {
+				d.tempGain = float64(gain[0])
+			}
+		} else {
+			panic("Trying to set temperature offset for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280PressureOffset option corrects the compensated pressures of the
+// BMP280Driver, in pascals, as WithBMP280TemperatureOffset the temperatures.
+func WithBMP280PressureOffset(offset float32, gain ...float32) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.pressOffset, d.pressGain = float64(offset), 1
+			if len(gain) > 0 {
+				d.pressGain = float64(gain[0])
+			}
+		} else {
+			panic("Trying to set pressure offset for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280TemperatureOversampling option sets the BMP280Driver temperature oversampling.
+func WithBMP280TemperatureOversampling(val BMP280Oversampling) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.tempOversampling = val
+		} else {
+			panic("Trying to set temperature oversampling for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280PressureOversampling option sets the BMP280Driver pressure oversampling.
+func WithBMP280PressureOversampling(val BMP280Oversampling) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.pressOversampling = val
+		} else {
+			panic("Trying to set pressure oversampling for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280IIRFilter option sets the BMP280Driver IIR filter coefficient.
+func WithBMP280IIRFilter(coeff BMP280FilterCoefficient) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.filter = coeff
+		} else {
+			panic("Trying to set IIR filter for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280StandbyTime option sets the BMP280Driver standby time between
+// two measurements in normal mode.
+func WithBMP280StandbyTime(val BMP280StandbyTime) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.standby = val
+		} else {
+			panic("Trying to set standby time for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280ReadRetries option sets how many times the BMP280Driver retries
+// a failed read, and the delay before the first retry. The delay doubles
+// with every further retry.
+func WithBMP280ReadRetries(retries int, delay time.Duration) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.retries = retries
+			d.retryDelay = delay
+		} else {
+			panic("Trying to set read retries for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280PollInterval option sets the interval at which the BMP280Driver
+// polls the device and emits the temperature and pressure events.
+// Polling is disabled by default, or when the interval is 0.
+func WithBMP280PollInterval(interval time.Duration) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.interval = interval
+		} else {
+			panic("Trying to set poll interval for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280TemperatureUnit option sets the unit in which the BMP280Driver
+// reports temperatures. Defaults to celsius degrees.
+func WithBMP280TemperatureUnit(unit TemperatureUnit) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.tempUnit = unit
+		} else {
+			panic("Trying to set temperature unit for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280PressureUnit option sets the unit in which the BMP280Driver
+// reports pressures. Defaults to pascals.
+func WithBMP280PressureUnit(unit PressureUnit) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.pressUnit = unit
+		} else {
+			panic("Trying to set pressure unit for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280MeasurementTimeout option makes SetPowerMode wait, for at most
+// the given timeout, until a measurement triggered by the forced mode is complete.
+func WithBMP280MeasurementTimeout(timeout time.Duration) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.measureTimeout = timeout
+		} else {
+			panic("Trying to set measurement timeout for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280Timeout option sets the wall-clock limit of every bus transaction
+// of the BMP280Driver, e.g. against a stuck bus. A transaction exceeding it
+// fails with ErrBMP280TransactionTimeout, and all the following ones with
+// ErrBMP280NeedsReset, until Reset is called. The transaction itself can not
+// be aborted, and goes on in the background until the connection returns.
+func WithBMP280Timeout(timeout time.Duration) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.timeout = timeout
+		} else {
+			panic("Trying to set timeout for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280Connection option sets a Connection the BMP280Driver uses on Start
+// instead of getting one from its Connector, e.g. to feed it canned register
+// values in tests.
+func WithBMP280Connection(conn Connection) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.customConnection = conn
+		} else {
+			panic("Trying to set connection for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280PressureTrend option makes the BMP280Driver keep the pressures
+// polled over the given window, e.g. 3 hours, for the PressureTrend method.
+// The threshold is the pressure change over the window, in pascals, beyond
+// which the pressure is rising or falling, e.g. 100 for ±1 hPa/3h.
+// It requires WithBMP280PollInterval.
+func WithBMP280PressureTrend(window time.Duration, threshold float32) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.pressTrend = newBMP280PressureHistory(window, threshold)
+		} else {
+			panic("Trying to set pressure trend for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280Transport option sets the register access the BMP280Driver uses
+// on Start instead of an i2c connection, e.g. a BMP280RegisterMap to run
+// without the device, or NewBMP280SPITransport for a device wired to SPI.
+// No Connector is needed then.
+func WithBMP280Transport(t BMP280Transport) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.customTransport = t
+		} else {
+			panic("Trying to set transport for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280ReadDelay option makes the BMP280Driver wait for the given delay
+// between writing the register address and reading the data, in two
+// transactions, for devices failing to answer the read right after the write.
+// It is ignored with WithBMP280Transport.
+func WithBMP280ReadDelay(delay time.Duration) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.readDelay = delay
+		} else {
+			panic("Trying to set read delay for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280ForcedMode option makes the BMP280Driver keep the device in sleep
+// mode, and trigger a forced measurement on every read. The read then waits
+// for the measurement to complete by polling the status register, for at most
+// the measurement timeout if set, or the MeasurementDuration otherwise.
+// If a settle duration is given, the read instead just waits for that long,
+// without polling the status register, e.g. to use the actual measurement
+// time of a device, shorter than the maximum of the datasheet.
+// With WithBMP280PressureOversampling(BMP280OversamplingSkip), only the
+// temperature is converted and read, for the lowest power consumption.
+func WithBMP280ForcedMode(settle ...time.Duration) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.forcedMode = true
+			d.powerMode = BMP280PowerModeSleep
+			d.forcedSettle = 0
+			if len(settle) > 0 {
+				d.forcedSettle = settle[0]
+			}
+		} else {
+			panic("Trying to set forced mode for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280Trace option installs a callback invoked on every register
+// access attempt, retries included, with the register address, the direction
+// "read" or "write", the transferred bytes and the error of the attempt. The
+// bytes of a failed read are nil. The callback is called with the bus lock
+// held, so it must not use the driver.
+func WithBMP280Trace(trace func(addr byte, dir string, data []byte, err error)) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.trace = trace
+		} else {
+			panic("Trying to set trace for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280MetricSink option installs a callback invoked after every
+// successful read with each value read, to update e.g. Prometheus gauges:
+// BMP280MetricTemperature in celsius degrees, BMP280MetricPressure in
+// pascals and BME280MetricHumidity in percent, whatever the configured
+// units. The callback must not use the driver.
+func WithBMP280MetricSink(sink func(name string, value float64)) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.metricSink = sink
+		} else {
+			panic("Trying to set metric sink for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280AddressDetection option makes the BMP280Driver try the alternate
+// address, 0x77 or 0x76, when no BMP280 is found at the configured or default
+// address on Start. The address of the found device is then kept.
+func WithBMP280AddressDetection() func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.autoDetect = true
+		} else {
+			panic("Trying to set address detection for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280Calibration option sets known calibration coefficients of the
+// device, for example read by CalibrationCoefficients of another driver
+// instance, so that they are not read from the device on Start and Reset.
+func WithBMP280Calibration(coefficients BMP280CalibrationCoefficients) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			*d.tpc = coefficients
+			d.presetCalibration = true
+		} else {
+			panic("Trying to set calibration for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280CalibrationParser option sets the function parsing the 24 bytes
+// of the calibration registers, from 0x88 to 0x9F, read on Start and Reset,
+// for clones with another layout than the one of the datasheet.
+func WithBMP280CalibrationParser(parse func(data []byte) (BMP280CalibrationCoefficients, error)) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.calibrationParser = parse
+		} else {
+			panic("Trying to set calibration parser for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280RoundTo option makes the BMP280Driver round the reported
+// temperatures and pressures, in the configured units, to the given number of
+// decimals, for example 2 for 0.01 celsius degrees, or 0 for 1 Pa. A negative
+// value, the default, disables the rounding.
+func WithBMP280RoundTo(decimals int) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.roundTo = decimals
+		} else {
+			panic("Trying to set rounding for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280MuxChannel option makes the BMP280Driver select the given channel,
+// 0 to 7, of a TCA9548A multiplexer at the given address before every
+// transaction. The multiplexer must be on the same bus as configured for the
+// driver. The transactions of all the drivers using a multiplexer are serialized.
+func WithBMP280MuxChannel(muxAddr byte, channel int) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.muxAddress = muxAddr
+			d.muxChannel = channel
+		} else {
+			panic("Trying to set mux channel for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280IntegerCompensation option makes the BMP280Driver compensate the
+// readings with the fixed-point algorithm of the datasheet, instead of the
+// floating-point one, e.g. on targets without a floating-point unit.
+func WithBMP280IntegerCompensation() func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.integerCompensation = true
+		} else {
+			panic("Trying to set integer compensation for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280WriteVerification option makes the BMP280Driver read back the
+// ctrl_meas and config registers after writing them, and return an error
+// wrapping ErrBMP280WriteVerification when they differ.
+func WithBMP280WriteVerification() func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.verifyWrites = true
+		} else {
+			panic("Trying to set write verification for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280MovingAverage option makes the BMP280Driver report the average of
+// the last window temperatures and pressures, while still measuring on every
+// call. This applies to all the values derived from them, but not to Read.
+// A window of 1, the default, disables the averaging.
+func WithBMP280MovingAverage(window int) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.tempAverage = newBMP280MovingAverage(window)
+			d.pressAverage = newBMP280MovingAverage(window)
+		} else {
+			panic("Trying to set moving average for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280KalmanFilter option runs the pressures of the BMP280Driver
+// through a one dimensional Kalman filter, with less lag than a moving
+// average for a given smoothing. The process noise variance q, in pascals
+// squared per sample, is how much the pressure is expected to change, and
+// the measurement noise variance r, in pascals squared, how noisy a reading
+// is. The filter starts at the first sample. As the moving average, this
+// applies to all the values derived from the pressure, but not to Read.
+// A non positive variance disables the filter.
+func WithBMP280KalmanFilter(q float32, r float32) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.pressKalman = newBMP280KalmanFilter(float64(q), float64(r))
+		} else {
+			panic("Trying to set Kalman filter for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280DiscardSamples option makes Start of the BMP280Driver read and
+// discard the given number of samples, e.g. while the IIR filter fills. In
+// normal mode, it waits for a new sample, at the output data rate, before
+// each read. In forced mode, each read triggers a measurement.
+func WithBMP280DiscardSamples(n int) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.discardSamples = n
+		} else {
+			panic("Trying to set discard samples for non-BMP280Driver")
+		}
+	}
+}
+
+// WithBMP280MinReadInterval option makes the BMP280Driver reuse the last
+// readings of the same kind, temperature only or combined, when read again
+// within the interval, instead of accessing the bus.
+func WithBMP280MinReadInterval(interval time.Duration) func(Config) {
+	return func(c Config) {
+		d, ok := bmp280DriverFromConfig(c)
+		if ok {
+			d.cache = nil
+			if interval > 0 {
+				d.cache = &bmp280ReadCache{interval: interval, entries: map[int]bmp280CacheEntry{}}
+			}
+		} else {
+			panic("Trying to set min read interval for non-BMP280Driver")
+		}
+	}
+}
+
+// bmp280DriverFromConfig returns the BMP280Driver an option is applied to,
+// which is the embedded one in case of the BME280Driver.
+func bmp280DriverFromConfig(c Config) (*BMP280Driver, bool) {
+	switch d := c.(type) {
+	case *BMP280Driver:
+		return d, true
+	case *BME280Driver:
+		return d.BMP280Driver, true
+	case *BoschEnvDriver:
+		return d.BMP280Driver, true
+	}
+	return nil, false
+}
+
+// Name returns the name of the device.
+func (d *BMP280Driver) Name() string {
+	return d.name
+}
+
+// SetName sets the name of the device.
+func (d *BMP280Driver) SetName(n string) {
+	d.name = n
+}
+
+// Connection returns the connection of the device, which is the Connector
+// if it is a gobot.Connection, and nil otherwise.
+func (d *BMP280Driver) Connection() gobot.Connection {
+	conn, _ := d.connector.(gobot.Connection)
+	return conn
+}
+
+// Clone returns a new, unstarted driver with the same connector and
+// configuration, to which the given options are then applied, e.g. to
+// use the same settings for devices at other addresses. The clone has a
+// default name, and its own calibration coefficients, averages and cached
+// readings.
+func (d *BMP280Driver) Clone(options ...func(Config)) *BMP280Driver {
+	c := d.clone()
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// clone copies the configuration of the driver, but none of its state.
+func (d *BMP280Driver) clone() *BMP280Driver {
+	c := *d
+	c.name = gobot.DefaultName("BMP280")
+	c.mutex = &sync.Mutex{}
+	c.Config = NewConfig()
+	c.WithBus(d.GetBusOrDefault(BusNotInitialized))
+	c.WithAddress(d.GetAddressOrDefault(AddressNotInitialized))
+	c.Commander = gobot.NewCommander()
+	c.Eventer = gobot.NewEventer()
+	c.halt = nil
+	c.started = false
+	c.transport = nil
+	c.muxConnection = nil
+	c.chipID = 0
+	c.version = 0
+	c.needsReset = false
+
+	tpc := d.calibration()
+	c.tpc = &tpc
+	c.tempAverage = d.tempAverage.clone()
+	c.pressAverage = d.pressAverage.clone()
+	c.pressKalman = d.pressKalman.clone()
+	if d.cache != nil {
+		c.cache = &bmp280ReadCache{interval: d.cache.interval, entries: map[int]bmp280CacheEntry{}}
+	}
+	c.climb = &bmp280VerticalSpeed{}
+	c.pressTrend = d.pressTrend.clone()
+	c.health = &bmp280Health{err: ErrBMP280NotStarted}
+	c.scratch = &bmp280Scratch{}
+	c.conversion = &bmp280Conversion{}
+	c.duration = c.MeasurementDuration
+	c.initVariant = nil
+
+	c.addEventsAndCommands()
+	return &c
+}
+
+// Start initializes the BMP280 and loads the calibration coefficients.
+// If a poll interval is set, the device is then read at that interval.
+// Calling Start again before Halt does nothing.
+// Emits the Events:
+//	temperature float32 - the current temperature, in the configured unit.
+//	pressure float32 - the current pressure, in the configured unit, unless skipped.
+//	error error - the error of a failed poll, instead of both above, or of a Trigger.
+//	ready time.Time - the completion of a measurement started by Trigger.
+func (d *BMP280Driver) Start() (err error) {
+	if d.started {
+		return nil
+	}
+	if err = d.start(); err != nil {
+		return err
+	}
+	if err = d.discard(d.OutputDataRate()); err != nil {
+		return err
+	}
+	d.health.set(nil)
+	d.poll()
+	d.started = true
+	return nil
+}
+
+func (d *BMP280Driver) start() (err error) {
+	if d.customTransport != nil {
+		d.setTransport(d.customTransport)
+		return d.initialization()
+	}
+	// the address of a custom connection is checked as well, as SetAddress does.
+	address := d.GetAddressOrDefault(bmp280Address)
+	if err = bmp280CheckAddress(address); err != nil {
+		return err
+	}
+	if err = d.connectMux(); err != nil {
+		return err
+	}
+	if d.customConnection != nil {
+		return d.useConnection(d.customConnection, address)
+	}
+
+	bus := d.GetBusOrDefault(d.connector.GetDefaultBus())
+	if err = d.connect(address, bus); err == nil || !d.autoDetect {
+		return err
+	}
+
+	alternate := bmp280AlternateAddress
+	if address == bmp280AlternateAddress {
+		alternate = bmp280Address
+	}
+	if d.connect(alternate, bus) != nil {
+		// report the error of the configured address.
+		return err
+	}
+	WithAddress(alternate)(d)
+	return nil
+}
+
+// bmp280CheckAddress returns ErrBMP280InvalidAddress if the address is not in
+// the 7 bit range usable by devices, 0x03 to 0x77.
+func bmp280CheckAddress(address int) error {
+	if address >= 0x03 && address <= 0x77 {
+		return nil
+	}
+	if address <= 0xff && address>>1 >= 0x03 && address>>1 <= 0x77 {
+		// e.g. 0xEC, the 8 bit write address of 0x76 found in some datasheets.
+		return fmt.Errorf("%w 0x%02X, use the 7 bit address 0x%02X", ErrBMP280InvalidAddress, address, address>>1)
+	}
+	return fmt.Errorf("%w 0x%02X, expected 0x03 to 0x77", ErrBMP280InvalidAddress, address)
+}
+
+// connectMux gets the connection to the multiplexer, if any.
+func (d *BMP280Driver) connectMux() (err error) {
+	if d.muxChannel < 0 {
+		return nil
+	}
+	if d.muxChannel > 7 {
+		return fmt.Errorf("BMP280: invalid mux channel %d", d.muxChannel)
+	}
+	if d.connector == nil {
+		return errors.New("BMP280: a connector is needed for the mux")
+	}
+	bus := d.GetBusOrDefault(d.connector.GetDefaultBus())
+	var conn Connection
+	if conn, err = d.connector.GetConnection(int(d.muxAddress), bus); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	d.muxConnection = conn
+	d.mutex.Unlock()
+	return nil
+}
+
+// selectMuxChannel selects the channel of the device on the multiplexer, if any.
+// The returned function must be called at the end of the transaction.
+func (d *BMP280Driver) selectMuxChannel() (func(), error) {
+	if d.muxConnection == nil {
+		return func() {}, nil
+	}
+	bmp280MuxMutex.Lock()
+	if err := d.muxConnection.WriteByte(1 << uint(d.muxChannel)); err != nil {
+		bmp280MuxMutex.Unlock()
+		return nil, err
+	}
+	return bmp280MuxMutex.Unlock, nil
+}
+
+// connect gets the connection to the given address and initializes the device.
+func (d *BMP280Driver) connect(address int, bus int) (err error) {
+	var conn Connection
+	if conn, err = d.connector.GetConnection(address, bus); err != nil {
+		return err
+	}
+	return d.useConnection(conn, address)
+}
+
+// useConnection sets the transport over the connection to the device at the
+// given address, and initializes the device.
+func (d *BMP280Driver) useConnection(conn Connection, address int) error {
+	d.setTransport(bmp280ConnectionTransport{connection: conn, address: address, readDelay: d.readDelay})
+	return d.initialization()
+}
+
+// setTransport sets the transport used by the reads and writes.
+func (d *BMP280Driver) setTransport(transport BMP280Transport) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.transport = transport
+}
+
+// connected returns whether a transport is set, by Start.
+func (d *BMP280Driver) connected() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.transport != nil
+}
+
+// poll starts reading the device at the configured interval, if any.
+func (d *BMP280Driver) poll() {
+	if d.interval <= 0 {
+		return
+	}
+	d.halt = make(chan bool)
+	go func(halt chan bool) {
+		timer := time.NewTimer(d.interval)
+		timer.Stop()
+		for {
+			d.pollOnce()
+
+			timer.Reset(d.interval)
+			select {
+			case <-timer.C:
+			case <-halt:
+				timer.Stop()
+				return
+			}
+		}
+	}(d.halt)
+}
+
+// pollOnce reads the device and publishes the readings, or the error. With
+// the pressure oversampling set to skip, only the temperature is read.
+func (d *BMP280Driver) pollOnce() {
+	if d.pressOversampling == BMP280OversamplingSkip {
+		temp, err := d.temperature()
+		d.health.set(err)
+		if err != nil {
+			d.Publish(d.Event(Error), err)
+			return
+		}
+		d.Publish(d.Event(BMP280TemperatureEvent), d.temperatureValue(temp))
+		return
+	}
+
+	temp, press, err := d.temperatureAndPressure()
+	d.health.set(err)
+	if err != nil {
+		d.Publish(d.Event(Error), err)
+		return
+	}
+	if d.pressTrend != nil {
+		d.pressTrend.add(press, time.Now())
+	}
+	d.Publish(d.Event(BMP280TemperatureEvent), d.temperatureValue(temp))
+	d.Publish(d.Event(BMP280PressureEvent), d.pressureValue(press))
+}
+
+// SetAddress changes the address of the device. If the driver is already
+// started, it connects to the device at the new address and initializes it.
+func (d *BMP280Driver) SetAddress(address int) error {
+	if err := bmp280CheckAddress(address); err != nil {
+		return err
+	}
+	d.WithAddress(address)
+	return d.reconnect()
+}
+
+// SetBus changes the bus of the device. If the driver is already started,
+// it connects to the device on the new bus and initializes it.
+func (d *BMP280Driver) SetBus(bus int) error {
+	d.WithBus(bus)
+	return d.reconnect()
+}
+
+// reconnect connects to the configured bus and address, if started.
+// The previous connection is not closed on purpose: it shares the bus
+// device of the adaptor, which closing it would close for all drivers.
+func (d *BMP280Driver) reconnect() error {
+	if !d.connected() {
+		return nil
+	}
+	if err := d.start(); err != nil {
+		return err
+	}
+	if d.initVariant != nil {
+		// e.g. the humidity measurement of the embedding BME280Driver.
+		return d.initVariant()
+	}
+	return nil
+}
+
+// SetSeaLevelPressure sets the reference pressure at sea level, in pascals,
+// used for the altitude calculation.
+func (d *BMP280Driver) SetSeaLevelPressure(press float32) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.seaLevelPressure = press
+}
+
+// seaLevel returns the reference pressure at sea level, in pascals.
+func (d *BMP280Driver) seaLevel() float32 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.seaLevelPressure
+}
+
+// State returns the configuration of the driver, to be persisted and
+// restored with WithBMP280State.
+func (d *BMP280Driver) State() BMP280State {
+	return BMP280State{
+		SeaLevelPressure:        d.seaLevel(),
+		StationAltitude:         d.stationAltitude,
+		TemperatureUnit:         d.tempUnit,
+		PressureUnit:            d.pressUnit,
+		TemperatureOversampling: d.tempOversampling,
+		PressureOversampling:    d.pressOversampling,
+		Filter:                  d.filter,
+		StandbyTime:             d.standby,
+	}
+}
+
+// SeaLevelPressureFromAltitude measures the current pressure, and derives the
+// reference pressure at sea level from the known altitude in meters, by inverting
+// the barometric formula of Altitude. The result, in pascals, is stored for the
+// subsequent altitude calculations and returned.
+func (d *BMP280Driver) SeaLevelPressureFromAltitude(knownAltitude float32) (press float32, err error) {
+	var p float64
+	if _, p, err = d.temperatureAndPressure(); err != nil {
+		return 0.0, err
+	}
+	press = bmp280SeaLevelPressureFromAltitude(float32(p), knownAltitude)
+	d.SetSeaLevelPressure(press)
+	return press, nil
+}
+
+// SetPowerMode sets the power mode of the device. Setting BMP280PowerModeForced
+// triggers a single measurement, after which the device returns to sleep mode.
+// If a measurement timeout is set, it then waits for the measurement to complete.
+func (d *BMP280Driver) SetPowerMode(mode BMP280PowerMode) (err error) {
+	if err = d.writeControl(mode); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	d.powerMode = mode
+	d.mutex.Unlock()
+	if mode == BMP280PowerModeForced && d.measureTimeout > 0 {
+		return d.waitMeasurement(d.measureTimeout)
+	}
+	return nil
+}
+
+// Status returns whether the device is running a conversion, and whether
+// it is copying the calibration data from its NVM to the image registers.
+func (d *BMP280Driver) Status() (measuring bool, updating bool, err error) {
+	var status []byte
+	if status, err = d.read(bmp280RegisterStatus, 1); err != nil {
+		return false, false, err
+	}
+	return status[0]&bmp280StatusMeasuring != 0, status[0]&bmp280StatusImUpdate != 0, nil
+}
+
+// Measuring returns whether the device is running a conversion.
+func (d *BMP280Driver) Measuring() (measuring bool, err error) {
+	measuring, _, err = d.Status()
+	return
+}
+
+// waitMeasurement blocks until the running conversion is complete,
+// or the timeout elapsed.
+func (d *BMP280Driver) waitMeasurement(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		measuring, err := d.Measuring()
+		if err != nil {
+			return err
+		}
+		if !measuring {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrBMP280MeasurementTimeout
+		}
+		time.Sleep(bmp280StatusPollInterval)
+	}
+}
+
+// MeasurementDuration returns the maximum duration of a measurement with the
+// configured oversampling, as given by the datasheet:
+//
+//		1.25ms + 2.3ms * osrs_t + (2.3ms * osrs_p + 0.575ms)
+//
+// where the terms of a skipped measurement are left out.
+func (d *BMP280Driver) MeasurementDuration() time.Duration {
+	return bmp280MeasurementDuration(d.tempOversampling, d.pressOversampling)
+}
+
+// Reset performs a soft reset of the device, and then reloads the
+// calibration coefficients and the configuration. It also recovers
+// the driver from a transaction timeout.
+func (d *BMP280Driver) Reset() (err error) {
+	d.mutex.Lock()
+	d.needsReset = false
+	d.mutex.Unlock()
+
+	if err = d.write(bmp280RegisterReset, bmp280CmdReset); err != nil {
+		return err
+	}
+	time.Sleep(bmp280StartupTime)
+	return d.initialization()
+}
+
+// CalibrationCoefficients returns a copy of the calibration coefficients
+// read from the device.
+func (d *BMP280Driver) CalibrationCoefficients() BMP280CalibrationCoefficients {
+	return d.calibration()
+}
+
+// calibration returns a copy of the calibration coefficients, which Reset
+// or a reconnection may reload concurrently.
+func (d *BMP280Driver) calibration() BMP280CalibrationCoefficients {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return *d.tpc
+}
+
+// CalibrationData reads the 24 bytes of the calibration registers, from dig_T1
+// to dig_P9, as they are on the bus, e.g. to parse them with ParseBMP280Calibration.
+func (d *BMP280Driver) CalibrationData() ([]byte, error) {
+	return d.read(bmp280RegisterCalib00, bmp280CalibrationLength)
+}
+
+// ReadRegisters reads n bytes starting at the given register, with the same
+// retries, locking and tracing as the driver itself. It is meant for advanced
+// uses, e.g. experimenting with registers the driver does not support.
+func (d *BMP280Driver) ReadRegisters(start byte, n int) ([]byte, error) {
+	if n <= 0 || int(start)+n > 0x100 {
+		return nil, fmt.Errorf("BMP280: invalid register range 0x%02X, %d bytes", start, n)
+	}
+	return d.read(start, n)
+}
+
+// WriteRegister writes a byte to the given register. It is meant for advanced
+// uses: the driver does not know about the change, e.g. a changed oversampling
+// is not reflected by Settings or MeasurementDuration.
+func (d *BMP280Driver) WriteRegister(reg byte, value byte) error {
+	return d.write(reg, value)
+}
+
+// Version returns the content of the version register 0xD1 read on Start,
+// which identifies the silicon revision of the device. The register is not
+// documented in the datasheet, so the values should only be compared between
+// devices, e.g. to correlate anomalies with production batches.
+func (d *BMP280Driver) Version() byte {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.version
+}
+
+// CompensationModel returns the algorithm used to compensate the readings,
+// e.g. for the provenance of logged datapoints: the device, whether the
+// floating-point or the fixed-point algorithm is used, and the datasheet
+// revision it follows, as "bmp280-float-bosch-rev1.11".
+func (d *BMP280Driver) CompensationModel() string {
+	return bmp280CompensationModel("bmp280", "rev1.11", d.integerCompensation)
+}
+
+func bmp280CompensationModel(device string, revision string, integer bool) string {
+	algorithm := "float"
+	if integer {
+		algorithm = "int"
+	}
+	return device + "-" + algorithm + "-bosch-" + revision
+}
+
+// GetChipID reads the chip id register of the device, which is 0x58 for
+// the BMP280 and 0x60 for the BME280.
+func (d *BMP280Driver) GetChipID() (id byte, err error) {
+	var data []byte
+	if data, err = d.read(bmp280RegisterChipID, 1); err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// SelfTest checks that the device is a BMP280 or BME280, that the calibration
+// coefficients are set, and that a measurement is within the operating range of
+// the sensor, -40 to 85 celsius degrees and 300 to 1100 hPa. It returns an error
+// describing the first failed check.
+func (d *BMP280Driver) SelfTest() (err error) {
+	var id byte
+	if id, err = d.GetChipID(); err != nil {
+		return fmt.Errorf("BMP280: self test: %w", err)
+	}
+	if id != bmp280ChipID && id != bme280ChipID {
+		return fmt.Errorf("BMP280: self test: %w 0x%02X", ErrBMP280BadChipID, id)
+	}
+	if d.calibration() == (BMP280CalibrationCoefficients{}) {
+		return fmt.Errorf("BMP280: self test: %w, all coefficients are zero", ErrBMP280InvalidCalibration)
+	}
+	var temp float64
+	if temp, err = d.selfTestTemperature(); err != nil {
+		return fmt.Errorf("BMP280: self test: %w", err)
+	}
+	if temp < bmp280MinTemperature || temp > bmp280MaxTemperature {
+		return fmt.Errorf("BMP280: self test: %w, temperature %.2f", ErrBMP280OutOfRange, temp)
+	}
+	return nil
+}
+
+// selfTestTemperature compensates a new sample, read bypassing the cache,
+// as measurement does without the filters and the metric sink, so that the
+// self test checks the device and not the previous readings.
+func (d *BMP280Driver) selfTestTemperature() (temp float64, err error) {
+	var rawT, rawP int32
+	if rawT, rawP, err = d.readRawTempPress(false); err != nil {
+		return 0.0, err
+	}
+	temp, tFine := d.calculateTemp(rawT)
+	var press float64
+	if press, err = d.calculatePress(rawP, tFine); err != nil {
+		return 0.0, err
+	}
+	if err = bmp280CheckPressure(press); err != nil {
+		return 0.0, err
+	}
+	return temp, nil
+}
+
+// OutputDataRate returns the number of measurements per second in normal
+// mode with the configured settings, as given by the datasheet:
+//
+//		1 / (measurement duration + standby time)
+func (d *BMP280Driver) OutputDataRate() float64 {
+	return bmp280OutputDataRate(d.duration(), d.standby.duration(false))
+}
+
+// Validate returns ErrBMP280InvalidSettings, describing the conflicting
+// settings, if the device is in normal mode and its output data rate with
+// the configured oversampling and standby time, see OutputDataRate, is below
+// the given target rate, in Hz. A standby time shorter than a measurement is
+// legal, it only lowers the rate.
+func (d *BMP280Driver) Validate(rate float64) error {
+	return d.validate(rate, d.standby.duration(false), fmt.Sprintf("temperature oversampling x%d and pressure oversampling x%d",
+		d.tempOversampling.ratio(), d.pressOversampling.ratio()))
+}
+
+// validate checks the output data rate with the given standby time against
+// the target rate, and describes the oversampling in the error.
+func (d *BMP280Driver) validate(rate float64, standby time.Duration, oversampling string) error {
+	if d.forcedMode || d.configuredPowerMode() != BMP280PowerModeNormal {
+		return nil
+	}
+	measure := d.duration()
+	if odr := bmp280OutputDataRate(measure, standby); odr < rate {
+		return fmt.Errorf("%w, measurements of up to %v with %s and the standby time of %v give %.1f Hz instead of %.1f Hz",
+			ErrBMP280InvalidSettings, measure, oversampling, standby, odr, rate)
+	}
+	return nil
+}
+
+func bmp280OutputDataRate(measure time.Duration, standby time.Duration) float64 {
+	return 1.0 / (measure + standby).Seconds()
+}
+
+// Settings reads back the ctrl_meas and config registers, and returns the
+// decoded settings of the device.
+func (d *BMP280Driver) Settings() (settings BMP280Settings, err error) {
+	var data []byte
+	if data, err = d.read(bmp280RegisterControl, 2); err != nil {
+		return settings, err
+	}
+	return BMP280Settings{
+		PowerMode:               BMP280PowerMode(data[0] & 0x03),
+		TemperatureOversampling: BMP280Oversampling(data[0] >> 5),
+		PressureOversampling:    BMP280Oversampling(data[0] >> 2 & 0x07),
+		Filter:                  BMP280FilterCoefficient(data[1] >> 2 & 0x07),
+		StandbyTime:             BMP280StandbyTime(data[1] >> 5),
+	}, nil
+}
+
+// String returns the name, bus, address and chip variant of the driver,
+// for diagnostic output. It does not access the device.
+func (d *BMP280Driver) String() string {
+	defaultBus := 0
+	if d.connector != nil {
+		defaultBus = d.connector.GetDefaultBus()
+	}
+	d.mutex.Lock()
+	chipID := d.chipID
+	d.mutex.Unlock()
+	variant := "unknown"
+	switch chipID {
+	case bmp280ChipID:
+		variant = "BMP280"
+	case bme280ChipID:
+		variant = "BME280"
+	}
+	return fmt.Sprintf("%s (%s, bus %d, address 0x%02x)", d.name, variant,
+		d.GetBusOrDefault(defaultBus), d.GetAddressOrDefault(bmp280Address))
+}
+
+// Halt stops polling the device and puts it into sleep mode.
+// The connection is left open, as it shares the bus device of the adaptor,
+// which closes it on Finalize.
+func (d *BMP280Driver) Halt() (err error) {
+	d.started = false
+	if d.halt != nil {
+		d.halt <- true
+		d.halt = nil
+	}
+	d.health.set(ErrBMP280NotStarted)
+	if !d.connected() {
+		return nil
+	}
+	// the configured power mode is kept, for a later Start.
+	return d.writeControl(BMP280PowerModeSleep)
+}
+
+// Temperature returns the current temperature, in the configured unit
+// (celsius degrees by default).
+func (d *BMP280Driver) Temperature() (temp float32, err error) {
+	var t float64
+	if t, err = d.temperature(); err != nil {
+		return 0.0, err
+	}
+	return d.temperatureValue(t), nil
+}
+
+// TemperatureCelsius returns the current temperature, in celsius degrees,
+// regardless of the configured unit.
+func (d *BMP280Driver) TemperatureCelsius() (temp float32, err error) {
+	var t float64
+	if t, err = d.temperature(); err != nil {
+		return 0.0, err
+	}
+	return float32(d.round(t)), nil
+}
+
+// temperature returns the temperature, in celsius degrees, reading only
+// the temperature registers.
+func (d *BMP280Driver) temperature() (temp float64, err error) {
+	var rawT int32
+	if rawT, err = d.rawTemp(); err != nil {
+		return 0.0, err
+	}
+	temp, _ = d.calculateTemp(rawT)
+	temp = d.tempAverage.add(temp)
+	d.observe(BMP280MetricTemperature, temp)
+	return temp, nil
+}
+
+// observe passes a value read to the metric sink, if any.
+func (d *BMP280Driver) observe(name string, value float64) {
+	if d.metricSink != nil {
+		d.metricSink(name, value)
+	}
+}
+
+// Pressure returns the current barometric pressure, in the configured unit
+// (pascals by default).
+func (d *BMP280Driver) Pressure() (press float32, err error) {
+	_, press, err = d.TemperatureAndPressure()
+	return
+}
+
+// TemperatureAndPressure returns the current temperature and the current
+// barometric pressure, in the configured units, both computed from the same sample.
+func (d *BMP280Driver) TemperatureAndPressure() (temp float32, press float32, err error) {
+	var t, p float64
+	if t, p, err = d.temperatureAndPressure(); err != nil {
+		return 0.0, 0.0, err
+	}
+	return d.temperatureValue(t), d.pressureValue(p), nil
+}
+
+// TypedTemperatureAndPressure is like TemperatureAndPressure, but returns
+// typed values, independent of the configured units and rounding.
+func (d *BMP280Driver) TypedTemperatureAndPressure() (temp Temperature, press Pressure, err error) {
+	var t, p float64
+	if t, p, err = d.temperatureAndPressure(); err != nil {
+		return 0.0, 0.0, err
+	}
+	return Temperature(t), Pressure(p), nil
+}
+
+// Read returns the temperature, pressure and altitude of a single sample,
+// so that the values are not skewed by different sampling instants.
+func (d *BMP280Driver) Read() (m BMP280Measurement, err error) {
+	var rawT, rawP int32
+	if rawT, rawP, err = d.rawTempPress(); err != nil {
+		return m, err
+	}
+	m, _, err = d.measurement(rawT, rawP)
+	return m, err
+}
+
+// RawTemperatureAndPressure returns the uncompensated 20 bit temperature and
+// pressure readings of the ADC, from the same sample.
+func (d *BMP280Driver) RawTemperatureAndPressure() (temp int32, press int32, err error) {
+	return d.rawTempPress()
+}
+
+// FixedTemperatureAndPressure returns the current temperature, in hundredths
+// of celsius degrees, and the current barometric pressure, in 1/256 pascals
+// (Q24.8), both from the same sample and compensated with the fixed-point
+// algorithm of the datasheet, whatever the configured units and compensation.
+func (d *BMP280Driver) FixedTemperatureAndPressure() (temp int32, press uint32, err error) {
+	var rawT, rawP int32
+	if rawT, rawP, err = d.rawTempPress(); err != nil {
+		return 0, 0, err
+	}
+	temp, tFine := d.calculateTempFixed(rawT)
+	if press, err = d.calculatePressFixed(rawP, tFine); err != nil {
+		return 0, 0, err
+	}
+	return temp, press, nil
+}
+
+// temperatureAndPressure returns the temperature, in celsius degrees,
+// and the pressure, in pascals, of the same sample.
+func (d *BMP280Driver) temperatureAndPressure() (temp float64, press float64, err error) {
+	var rawT, rawP int32
+	if rawT, rawP, err = d.rawTempPress(); err != nil {
+		return 0.0, 0.0, err
+	}
+	temp, tFine := d.calculateTemp(rawT)
+	if press, err = d.calculatePress(rawP, tFine); err != nil {
+		return 0.0, 0.0, err
+	}
+	if err = bmp280CheckPressure(press); err != nil {
+		return 0.0, 0.0, err
+	}
+	press = d.pressKalman.add(press)
+	temp, press = d.tempAverage.add(temp), d.pressAverage.add(press)
+	d.observe(BMP280MetricTemperature, temp)
+	d.observe(BMP280MetricPressure, press)
+	return temp, press, nil
+}
+
+// measurement compensates the raw readings into a BMP280Measurement,
+// and also returns the fine temperature.
+func (d *BMP280Driver) measurement(rawT int32, rawP int32) (m BMP280Measurement, tFine int32, err error) {
+	temp, tFine := d.calculateTemp(rawT)
+	press, err := d.calculatePress(rawP, tFine)
+	if err != nil {
+		return m, 0, err
+	}
+	if err = bmp280CheckPressure(press); err != nil {
+		return m, 0, err
+	}
+	m = BMP280Measurement{
+		Temperature: d.temperatureValue(temp),
+		Pressure:    d.pressureValue(press),
+		Altitude:    bmp280Altitude(float32(press), d.seaLevel()),
+		Time:        time.Now(),
+		tempUnit:    d.tempUnit,
+		pressUnit:   d.pressUnit,
+	}
+	d.observe(BMP280MetricTemperature, temp)
+	d.observe(BMP280MetricPressure, press)
+	return m, tFine, nil
+}
+
+// Altitude returns the current altitude in meters, derived from the current
+// barometric pressure using the international barometric formula:
+//
+//		altitude = 44330 * (1 - (p / p0)^(1 / 5.255))
+//
+// where p is the measured pressure and p0 the configured pressure at sea level,
+// both in pascals regardless of the configured pressure unit. It assumes the
+// temperature profile of the standard atmosphere, see AltitudeCompensated.
+func (d *BMP280Driver) Altitude() (alt float32, err error) {
+	var press float64
+	if _, press, err = d.temperatureAndPressure(); err != nil {
+		return 0.0, err
+	}
+	return bmp280Altitude(float32(press), d.seaLevel()), nil
+}
+
+// AltitudeCompensated returns the current altitude in meters, derived from
+// the current barometric pressure and temperature of the same sample using
+// the hypsometric formula:
+//
+//		altitude = ((p0 / p)^(1 / 5.257) - 1) * (T + 273.15) / 0.0065
+//
+// where p and p0 are as for Altitude, and T is the measured temperature in
+// celsius degrees, instead of the one of the standard atmosphere. It is more
+// accurate near the ground, as long as the sensor has the air temperature.
+func (d *BMP280Driver) AltitudeCompensated() (alt float32, err error) {
+	var temp, press float64
+	if temp, press, err = d.temperatureAndPressure(); err != nil {
+		return 0.0, err
+	}
+	return bmp280HypsometricAltitude(press, float64(d.seaLevel()), temp), nil
+}
+
+// discard reads and discards the configured number of samples, reading no
+// faster than the given output data rate in normal mode. The readings are
+// neither averaged nor cached.
+func (d *BMP280Driver) discard(rate float64) error {
+	for i := 0; i < d.discardSamples; i++ {
+		if !d.forcedMode {
+			time.Sleep(time.Duration(float64(time.Second) / rate))
+		}
+		if err := d.trigger(); err != nil {
+			return err
+		}
+		if _, err := d.read(bmp280RegisterPressureData, 6); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LastError returns the error of the most recent poll, or nil if it
+// succeeded. Without a poll interval, it is the outcome of Start. It is
+// ErrBMP280NotStarted before Start and after Halt. It does not access the
+// device, e.g. to be called by a health check.
+func (d *BMP280Driver) LastError() error {
+	return d.health.get()
+}
+
+// Healthy returns whether LastError is nil.
+func (d *BMP280Driver) Healthy() bool {
+	return d.health.get() == nil
+}
+
+// VerticalSpeed returns the vertical speed, in meters per second, from the
+// altitude change since the previous call, and 0 on the first call. Upwards
+// is positive. As the altitude is derived from the noisy pressure, a moving
+// average or the IIR filter is recommended.
+func (d *BMP280Driver) VerticalSpeed() (speed float32, err error) {
+	var alt float32
+	if alt, err = d.Altitude(); err != nil {
+		return 0.0, err
+	}
+	return d.climb.update(alt, time.Now()), nil
+}
+
+// PressureTrend returns the tendency of the polled pressure over the window
+// set with WithBMP280PressureTrend, and its rate of change in pascals per
+// hour, the least squares slope of the samples of the window. The pressure
+// is rising or falling if it changes by more than the threshold over the
+// window at this rate, and steady otherwise. Until the window is filled, the
+// rate of the samples polled so far is extrapolated to it. An error is
+// returned before two samples have been polled, or without the
+// WithBMP280PressureTrend option.
+func (d *BMP280Driver) PressureTrend() (trend BMP280PressureTrend, rate float32, err error) {
+	if d.pressTrend == nil {
+		return BMP280PressureSteady, 0.0, errors.New("BMP280: pressure trend not enabled, see WithBMP280PressureTrend")
+	}
+	var r float64
+	trend, r, err = d.pressTrend.trend()
+	return trend, float32(r), err
+}
+
+// RelativePressure returns the current barometric pressure reduced to sea level,
+// in the configured unit, as reported by weather stations. It is computed from
+// the pressure p and temperature T, in celsius degrees, of the same sample, and
+// the configured station altitude h in meters:
+//
+//		p0 = p * (1 - 0.0065 * h / (T + 0.0065 * h + 273.15))^-5.257
+func (d *BMP280Driver) RelativePressure() (press float32, err error) {
+	var t, p float64
+	if t, p, err = d.temperatureAndPressure(); err != nil {
+		return 0.0, err
+	}
+	h := 0.0065 * float64(d.stationAltitude)
+	p0 := p * math.Pow(1.0-h/(t+h+273.15), -5.257)
+	return d.pressureValue(p0), nil
+}
+
+// TemperatureWithContext is like Temperature, but returns ctx.Err() as soon
+// as the context is done. The abandoned read still completes in the background,
+// and holds the bus lock until the connection returns.
+func (d *BMP280Driver) TemperatureWithContext(ctx context.Context) (temp float32, err error) {
+	return bmp280WithContext(ctx, d.Temperature)
+}
+
+// PressureWithContext is like Pressure, but returns ctx.Err() as soon
+// as the context is done.
+func (d *BMP280Driver) PressureWithContext(ctx context.Context) (press float32, err error) {
+	return bmp280WithContext(ctx, d.Pressure)
+}
+
+// AltitudeWithContext is like Altitude, but returns ctx.Err() as soon
+// as the context is done.
+func (d *BMP280Driver) AltitudeWithContext(ctx context.Context) (alt float32, err error) {
+	return bmp280WithContext(ctx, d.Altitude)
+}
+
+// bmp280WithContext runs f in a goroutine, and waits for either its
+// result or the context to be done.
+func bmp280WithContext(ctx context.Context, f func() (float32, error)) (float32, error) {
+	type result struct {
+		val float32
+		err error
+	}
+	if err := ctx.Err(); err != nil {
+		return 0.0, err
+	}
+	done := make(chan result, 1)
+	go func() {
+		val, err := f()
+		done <- result{val, err}
+	}()
+	select {
+	case r := <-done:
+		return r.val, r.err
+	case <-ctx.Done():
+		return 0.0, ctx.Err()
+	}
+}
+
+// temperatureValue converts a temperature in celsius degrees to the reported value.
+func (d *BMP280Driver) temperatureValue(temp float64) float32 {
+	return float32(d.round(d.tempUnit.fromCelsius(temp)))
+}
+
+// pressureValue converts a pressure in pascals to the reported value.
+func (d *BMP280Driver) pressureValue(press float64) float32 {
+	return float32(d.round(d.pressUnit.fromPascal(press)))
+}
+
+// round rounds val to the configured number of decimals, if any.
+func (d *BMP280Driver) round(val float64) float64 {
+	if d.roundTo < 0 {
+		return val
+	}
+	p := math.Pow(10, float64(d.roundTo))
+	return math.Round(val*p) / p
+}
+
+// initialization verifies the chip id, reads the calibration coefficients
+// unless they are already known, and configures the device.
+func (d *BMP280Driver) initialization() (err error) {
+	var id []byte
+	if id, err = d.read(bmp280RegisterChipID, 1); err != nil {
+		return err
+	}
+	// the BME280 is register compatible, so it is accepted as well.
+	if id[0] != bmp280ChipID && id[0] != bme280ChipID {
+		return fmt.Errorf("%w 0x%02X, not a BMP280", ErrBMP280BadChipID, id[0])
+	}
+
+	var version []byte
+	if version, err = d.read(bmp280RegisterVersion, 1); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	d.chipID, d.version = id[0], version[0]
+	d.mutex.Unlock()
+
+	if !d.presetCalibration {
+		if err = d.readCalibration(); err != nil {
+			return err
+		}
+	}
+
+	if err = d.writeControl(BMP280PowerModeSleep); err != nil {
+		return err
+	}
+
+	// the config register may be ignored in normal mode, so write it while sleeping.
+	if err = d.writeConfig(); err != nil {
+		return err
+	}
+
+	return d.enterPowerMode()
+}
+
+// enterPowerMode writes the ctrl_meas register with the configured power
+// mode. In normal mode, it then waits for the first measurement, as the data
+// registers hold their reset value until it completes.
+func (d *BMP280Driver) enterPowerMode() error {
+	mode := d.configuredPowerMode()
+	if err := d.writeControl(mode); err != nil {
+		return err
+	}
+	if mode == BMP280PowerModeNormal {
+		time.Sleep(d.duration())
+	}
+	return nil
+}
+
+// configuredPowerMode returns the power mode set by the options or SetPowerMode.
+func (d *BMP280Driver) configuredPowerMode() BMP280PowerMode {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.powerMode
+}
+
+// writeControl writes the ctrl_meas register with the configured oversampling
+// and the given power mode.
+func (d *BMP280Driver) writeControl(mode BMP280PowerMode) error {
+	ctrl := byte(d.tempOversampling)<<5 | byte(d.pressOversampling)<<2 | byte(mode)
+	// the device returns to sleep mode once a forced measurement is done.
+	var mask byte = 0xff
+	if mode == BMP280PowerModeForced {
+		mask = 0xfc
+	}
+	return d.writeVerified(bmp280RegisterControl, ctrl, mask)
+}
+
+// writeConfig writes the config register with the configured standby time and IIR filter.
+func (d *BMP280Driver) writeConfig() error {
+	return d.writeVerified(bmp280RegisterConfig, byte(d.standby)<<5|byte(d.filter)<<2, 0xfc)
+}
+
+// writeVerified writes a register and, if write verification is enabled,
+// reads it back and compares the bits of the mask.
+func (d *BMP280Driver) writeVerified(address byte, val byte, mask byte) (err error) {
+	if err = d.write(address, val); err != nil || !d.verifyWrites {
+		return err
+	}
+	var data []byte
+	if data, err = d.read(address, 1); err != nil {
+		return err
+	}
+	if data[0]&mask != val&mask {
+		return fmt.Errorf("%w, register 0x%02X reads 0x%02X instead of 0x%02X",
+			ErrBMP280WriteVerification, address, data[0], val)
+	}
+	return nil
+}
+
+// trigger starts a forced measurement and waits for it to complete,
+// if the driver is in forced mode. The device then returns to sleep mode.
+func (d *BMP280Driver) trigger() error {
+	if !d.forcedMode || d.conversion.take() {
+		return nil
+	}
+	if err := d.writeControl(BMP280PowerModeForced); err != nil {
+		return err
+	}
+	return d.settle()
+}
+
+// Trigger starts a forced measurement and returns without waiting for it.
+// Once completed, the ready event is emitted, or the error event if waiting
+// failed, and the next read returns that measurement instead of triggering
+// another one. This synchronizes the sampling of several devices: trigger
+// all of them, then read each one on its ready event. It returns
+// ErrBMP280InvalidSettings without WithBMP280ForcedMode.
+func (d *BMP280Driver) Trigger() error {
+	if !d.forcedMode {
+		return fmt.Errorf("%w, Trigger needs the forced mode", ErrBMP280InvalidSettings)
+	}
+	if err := d.writeControl(BMP280PowerModeForced); err != nil {
+		return err
+	}
+	go func() {
+		if err := d.settle(); err != nil {
+			d.Publish(d.Event(Error), err)
+			return
+		}
+		d.conversion.set()
+		d.Publish(d.Event(BMP280ReadyEvent), time.Now())
+	}()
+	return nil
+}
+
+// settle waits for a forced measurement to complete.
+func (d *BMP280Driver) settle() error {
+	if d.forcedSettle > 0 {
+		time.Sleep(d.forcedSettle)
+		return nil
+	}
+	timeout := d.measureTimeout
+	if timeout <= 0 {
+		timeout = d.duration()
+	}
+	return d.waitMeasurement(timeout)
+}
+
+// bmp280ReadCache keeps the last readings of the data registers.
+type bmp280ReadCache struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	entries  map[int]bmp280CacheEntry
+}
+
+type bmp280CacheEntry struct {
+	time time.Time
+	data []byte
+}
+
+// readData reads the data registers starting at the given one into data,
+// after triggering a measurement in forced mode. With a min read interval,
+// recent readings of the same registers are returned instead.
+func (d *BMP280Driver) readData(address byte, data []byte) (err error) {
+	if d.cache == nil {
+		if err = d.trigger(); err != nil {
+			return err
+		}
+		return d.readFrame(address, data)
+	}
+
+	d.cache.mutex.Lock()
+	defer d.cache.mutex.Unlock()
+	key := int(address)<<8 | len(data)
+	e, ok := d.cache.entries[key]
+	if ok && time.Since(e.time) < d.cache.interval {
+		copy(data, e.data)
+		return nil
+	}
+	if err = d.trigger(); err != nil {
+		return err
+	}
+	if err = d.readFrame(address, data); err != nil {
+		return err
+	}
+	d.cache.entries[key] = bmp280CacheEntry{time: time.Now(), data: append(e.data[:0], data...)}
+	return nil
+}
+
+// readFrame reads the data registers, and reads them once more if the frame
+// is corrupt, without triggering a new measurement.
+func (d *BMP280Driver) readFrame(address byte, data []byte) (err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = d.readInto(address, data); err != nil {
+			return err
+		}
+		if !bmp280CorruptFrame(data) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: % X", ErrBMP280CorruptFrame, data)
+}
+
+// bmp280CorruptFrame returns whether the 20 bit readings starting the data
+// can not come from the device: the low nibble of the xlsb registers always
+// reads 0, and a msb of 0xFF is beyond the range of the ADC. Both are seen
+// when the frame is shifted by a byte. The humidity of the BME280 following
+// them has no such constraint.
+func bmp280CorruptFrame(data []byte) bool {
+	for i := 0; i+3 <= len(data) && i < 6; i += 3 {
+		if data[i] == 0xff || data[i+2]&0x0f != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *BMP280Driver) rawTempPress() (temp int32, press int32, err error) {
+	return d.readRawTempPress(true)
+}
+
+// readRawTempPress reads the raw temperature and pressure, through the cache
+// if any and cached is set, or from a new sample otherwise.
+func (d *BMP280Driver) readRawTempPress(cached bool) (temp int32, press int32, err error) {
+	if err = d.checkPressureEnabled(); err != nil {
+		return 0, 0, err
+	}
+	d.scratch.mutex.Lock()
+	defer d.scratch.mutex.Unlock()
+	data := d.scratch.data[:6]
+	if cached {
+		err = d.readData(bmp280RegisterPressureData, data)
+	} else if err = d.trigger(); err == nil {
+		err = d.readFrame(bmp280RegisterPressureData, data)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	press = int32(data[0])<<12 | int32(data[1])<<4 | int32(data[2])>>4
+	temp = int32(data[3])<<12 | int32(data[4])<<4 | int32(data[5])>>4
+	if err = d.checkRaw(temp, press); err != nil {
+		return 0, 0, err
+	}
+	return
+}
+
+// checkRaw returns ErrBMP280NoMeasurement if a raw reading is the reset value
+// of the data registers, e.g. because the device never left sleep mode. The
+// pressure is not checked if skipped, see checkPressureEnabled.
+func (d *BMP280Driver) checkRaw(temp int32, press int32) error {
+	if temp == bmp280RawReset {
+		return d.noMeasurement("temperature", d.tempOversampling)
+	}
+	if press == bmp280RawReset && d.pressOversampling != BMP280OversamplingSkip {
+		return d.noMeasurement("pressure", d.pressOversampling)
+	}
+	return nil
+}
+
+// checkPressureEnabled returns ErrBMP280NoMeasurement without accessing the
+// device if the pressure oversampling is set to skip.
+func (d *BMP280Driver) checkPressureEnabled() error {
+	if d.pressOversampling == BMP280OversamplingSkip {
+		return d.noMeasurement("pressure", d.pressOversampling)
+	}
+	return nil
+}
+
+func (d *BMP280Driver) noMeasurement(name string, oversampling BMP280Oversampling) error {
+	if oversampling == BMP280OversamplingSkip {
+		return fmt.Errorf("%w (%s measurement skipped)", ErrBMP280NoMeasurement, name)
+	}
+	if d.configuredPowerMode() == BMP280PowerModeNormal {
+		return fmt.Errorf("%w (no measurement completed yet)", ErrBMP280NoMeasurement)
+	}
+	return fmt.Errorf("%w (chip in sleep mode)", ErrBMP280NoMeasurement)
+}
+
+// rawTemp reads only the 3 temperature registers, which is cheaper than
+// the combined read when the pressure is not needed.
+func (d *BMP280Driver) rawTemp() (temp int32, err error) {
+	d.scratch.mutex.Lock()
+	defer d.scratch.mutex.Unlock()
+	data := d.scratch.data[:3]
+	if err = d.readData(bmp280RegisterTempData, data); err != nil {
+		return 0, err
+	}
+	temp = int32(data[0])<<12 | int32(data[1])<<4 | int32(data[2])>>4
+	if temp == bmp280RawReset {
+		return 0, d.noMeasurement("temperature", d.tempOversampling)
+	}
+	return
+}
+
+// calculateTemp returns the corrected temperature in celsius degrees, and
+// the fine temperature.
+func (d *BMP280Driver) calculateTemp(rawTemp int32) (float64, int32) {
+	var temp float64
+	var tFine int32
+	if d.integerCompensation {
+		var fixed int32
+		fixed, tFine = d.calculateTempFixed(rawTemp)
+		temp = float64(fixed) / 100.0
+	} else {
+		temp, tFine = CompensateBMP280Temperature(rawTemp, d.calibration())
+	}
+	return temp*d.tempGain + d.tempOffset, tFine
+}
+
+// calculatePress returns the corrected pressure in pascals, or
+// ErrBMP280InvalidCalibration if the coefficients cause a division by zero,
+// rather than a vacuum reading.
+func (d *BMP280Driver) calculatePress(rawPress int32, tFine int32) (float64, error) {
+	var press float64
+	if d.integerCompensation {
+		fixed, err := d.calculatePressFixed(rawPress, tFine)
+		if err != nil {
+			return 0.0, err
+		}
+		press = float64(fixed) / 256.0
+	} else {
+		var err error
+		if press, err = CompensateBMP280Pressure(rawPress, tFine, d.calibration()); err != nil {
+			return 0.0, err
+		}
+	}
+	return press*d.pressGain + d.pressOffset, nil
+}
+
+func bmp280PressureDivisionByZero(c BMP280CalibrationCoefficients) error {
+	return fmt.Errorf("%w, pressure compensation divides by zero, dig_P1 %d", ErrBMP280InvalidCalibration, c.P1)
+}
+
+// CompensateBMP280Temperature returns the temperature, in celsius degrees, of
+// a raw 20 bit reading with the given calibration coefficients, and the fine
+// temperature needed to compensate the pressure. It uses the floating-point
+// algorithm of the datasheet, as the driver does by default, e.g. to
+// compensate logged raw readings offline.
+func CompensateBMP280Temperature(rawTemp int32, c BMP280CalibrationCoefficients) (temp float64, tFine int32) {
+	tcvar1 := ((float64(rawTemp) / 16384.0) - (float64(c.T1) / 1024.0)) * float64(c.T2)
+	tcvar2 := (((float64(rawTemp) / 131072.0) - (float64(c.T1) / 8192.0)) * ((float64(rawTemp) / 131072.0) - float64(c.T1)/8192.0)) * float64(c.T3)
+	temperatureComp := (tcvar1 + tcvar2) / 5120.0
+
+	// as in the Bosch reference code, the temperature is computed from the
+	// exact sum, and only the fine temperature is truncated to an integer.
+	tFine = int32(tcvar1 + tcvar2)
+	return temperatureComp, tFine
+}
+
+// CompensateBMP280Pressure returns the pressure, in pascals, of a raw 20 bit
+// reading with the given calibration coefficients and the fine temperature of
+// the same sample, as returned by CompensateBMP280Temperature. It returns
+// ErrBMP280InvalidCalibration for coefficients causing a division by zero.
+func CompensateBMP280Pressure(rawPress int32, tFine int32, c BMP280CalibrationCoefficients) (float64, error) {
+	var pcvar1, pcvar2 float64
+
+	pcvar1 = (float64(tFine) / 2.0) - 64000.0
+	pcvar2 = pcvar1 * pcvar1 * (float64(c.P6)) / 32768.0
+	pcvar2 = pcvar2 + pcvar1*(float64(c.P5))*2.0
+	pcvar2 = (pcvar2 / 4.0) + (float64(c.P4) * 65536.0)
+	pcvar1 = ((float64(c.P3) * pcvar1 * pcvar1 / 524288.0) + (float64(c.P2) * pcvar1)) / 524288.0
+	pcvar1 = (1.0 + pcvar1/32768.0) * (float64(c.P1))
+
+	if pcvar1 == 0 {
+		return 0, bmp280PressureDivisionByZero(c) // avoid exception caused by division by zero
+	}
+	pressureComp := 1048576.0 - float64(rawPress)
+	pressureComp = (pressureComp - (pcvar2 / 4096.0)) * 6250.0 / pcvar1
+	pcvar1 = (float64(c.P9)) * pressureComp * pressureComp / 2147483648.0
+	pcvar2 = pressureComp * (float64(c.P8)) / 32768.0
+	pressureComp = pressureComp + (pcvar1+pcvar2+(float64(c.P7)))/16.0
+
+	return pressureComp, nil
+}
+
+// calculateTempFixed returns the temperature, in hundredths of celsius
+// degrees, and the fine temperature, as the 32 bit integer reference code.
+func (d *BMP280Driver) calculateTempFixed(rawTemp int32) (int32, int32) {
+	c := d.calibration()
+	t1 := int32(c.T1)
+	tcvar1 := (((rawTemp >> 3) - (t1 << 1)) * int32(c.T2)) >> 11
+	tcvar2 := (((((rawTemp >> 4) - t1) * ((rawTemp >> 4) - t1)) >> 12) * int32(c.T3)) >> 14
+	tFine := tcvar1 + tcvar2
+	return (tFine*5 + 128) >> 8, tFine
+}
+
+// calculatePressFixed returns the pressure, in 1/256 pascals, as the 64 bit
+// integer reference code, or ErrBMP280InvalidCalibration instead of dividing
+// by zero.
+func (d *BMP280Driver) calculatePressFixed(rawPress int32, tFine int32) (uint32, error) {
+	c := d.calibration()
+	var pcvar1, pcvar2, p int64
+
+	pcvar1 = int64(tFine) - 128000
+	pcvar2 = pcvar1 * pcvar1 * int64(c.P6)
+	pcvar2 = pcvar2 + ((pcvar1 * int64(c.P5)) << 17)
+	pcvar2 = pcvar2 + (int64(c.P4) << 35)
+	pcvar1 = ((pcvar1 * pcvar1 * int64(c.P3)) >> 8) + ((pcvar1 * int64(c.P2)) << 12)
+	pcvar1 = ((int64(1) << 47) + pcvar1) * int64(c.P1) >> 33
+
+	if pcvar1 == 0 {
+		return 0, bmp280PressureDivisionByZero(c)
+	}
+	p = 1048576 - int64(rawPress)
+	p = (((p << 31) - pcvar2) * 3125) / pcvar1
+	pcvar1 = (int64(c.P9) * (p >> 13) * (p >> 13)) >> 25
+	pcvar2 = (int64(c.P8) * p) >> 19
+	p = ((p + pcvar1 + pcvar2) >> 8) + (int64(c.P7) << 4)
+
+	return uint32(p), nil
+}
+
+// read reads n bytes starting at the given register, retrying up to the
+// configured number of times with a doubling delay between the attempts.
+func (d *BMP280Driver) read(address byte, n int) (buf []byte, err error) {
+	buf = make([]byte, n)
+	if err = d.readInto(address, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readInto is read into the given buffer, without allocating unless a
+// timeout or a trace is set.
+func (d *BMP280Driver) readInto(address byte, buf []byte) (err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.transport == nil {
+		return ErrBMP280NotStarted
+	}
+	if d.needsReset {
+		return ErrBMP280NeedsReset
+	}
+	done, err := d.selectMuxChannel()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	delay := d.retryDelay
+	for i := 0; ; i++ {
+		err = d.readAttempt(address, buf)
+		if d.trace != nil {
+			if err != nil {
+				d.trace(address, "read", nil, err)
+			} else {
+				d.trace(address, "read", append([]byte(nil), buf...), nil)
+			}
+		}
+		if err == nil {
+			break
+		}
+		if i >= d.retries || d.needsReset {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if err != nil {
+		if d.retries > 0 {
+			err = fmt.Errorf("BMP280: read failed after %d retries: %w", d.retries, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// readAttempt reads the registers once. With a timeout, it reads into a new
+// buffer, as a timed out transaction may still write it later.
+func (d *BMP280Driver) readAttempt(address byte, buf []byte) error {
+	if d.timeout <= 0 {
+		return d.transport.ReadRegisters(address, buf)
+	}
+	b := make([]byte, len(buf))
+	if err := d.transaction(func() error { return d.transport.ReadRegisters(address, b) }); err != nil {
+		return err
+	}
+	copy(buf, b)
+	return nil
+}
+
+func (d *BMP280Driver) write(address byte, val byte) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.transport == nil {
+		return ErrBMP280NotStarted
+	}
+	if d.needsReset {
+		return ErrBMP280NeedsReset
+	}
+	done, err := d.selectMuxChannel()
+	if err != nil {
+		return err
+	}
+	defer done()
+	err = d.transaction(func() error { return d.transport.WriteRegister(address, val) })
+	if d.trace != nil {
+		d.trace(address, "write", []byte{val}, err)
+	}
+	return err
+}
+
+// transaction runs the given bus transaction, bounded by the timeout if set.
+// On a timeout, the driver needs a Reset, as the transaction may be going on.
+func (d *BMP280Driver) transaction(f func() error) error {
+	if d.timeout <= 0 {
+		return f()
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- f()
+	}()
+	timer := time.NewTimer(d.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		d.needsReset = true
+		return fmt.Errorf("%w after %v", ErrBMP280TransactionTimeout, d.timeout)
+	}
+}
+
+// readCalibration reads the 12 calibration coefficients from the device.
+func (d *BMP280Driver) readCalibration() (err error) {
+	var coefficients []byte
+	if coefficients, err = d.read(bmp280RegisterCalib00, bmp280CalibrationLength); err != nil {
+		return err
+	}
+	// a device that does not respond reads as all zeros or all ones.
+	if bmp280AllBytesEqual(coefficients, 0x00) || bmp280AllBytesEqual(coefficients, 0xff) {
+		return ErrBMP280InvalidCalibration
+	}
+	parse := bmp280ParseCalibration
+	if d.calibrationParser != nil {
+		parse = d.calibrationParser
+	}
+	var tpc BMP280CalibrationCoefficients
+	if tpc, err = parse(coefficients); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	*d.tpc = tpc
+	d.mutex.Unlock()
+	return nil
+}
+
+// bmp280ParseCalibration parses the 24 bytes of the calibration registers,
+// where the coefficients are stored in little endian order, from dig_T1 to dig_P9.
+func bmp280ParseCalibration(data []byte) (c BMP280CalibrationCoefficients, err error) {
+	return ParseBMP280Calibration(data, binary.LittleEndian)
+}
+
+// ParseBMP280Calibration parses the 24 bytes of the calibration registers, as
+// returned by CalibrationData, in the given byte order. The device stores the
+// coefficients in little endian order. Parsing them in big endian order too is
+// a diagnostic: if those turn out plausible, the bus swaps the bytes.
+func ParseBMP280Calibration(data []byte, order binary.ByteOrder) (c BMP280CalibrationCoefficients, err error) {
+	if len(data) != bmp280CalibrationLength {
+		return c, fmt.Errorf("%w, expected %d bytes, got %d", ErrBMP280InvalidCalibration, bmp280CalibrationLength, len(data))
+	}
+	err = binary.Read(bytes.NewReader(data), order, &c)
+	return c, err
+}
+
+// bmp280AllBytesEqual returns whether all the bytes of data are equal to b.
+func bmp280AllBytesEqual(data []byte, b byte) bool {
+	for _, v := range data {
+		if v != b {
+			return false
+		}
+	}
+	return true
+}
+
+// bmp280SeaLevelPressureFromAltitude converts a pressure measured at the given
+// altitude to the pressure at sea level.
+func bmp280SeaLevelPressureFromAltitude(press float32, alt float32) float32 {
+	return float32(float64(press) / math.Pow(1.0-float64(alt)/44330.0, 5.255))
+}
+
+// bmp280Scratch is the buffer of the data register reads, reused to spare
+// an allocation per read.
+type bmp280Scratch struct {
+	mutex sync.Mutex
+	data  [8]byte
+}
+
+// bmp280Conversion records a forced measurement started by Trigger,
+// completed and not read yet.
+type bmp280Conversion struct {
+	mutex sync.Mutex
+	done  bool
+}
+
+func (c *bmp280Conversion) set() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.done = true
+}
+
+// take returns whether a measurement is completed, and clears it.
+func (c *bmp280Conversion) take() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	done := c.done
+	c.done = false
+	return done
+}
+
+// bmp280Health keeps the outcome of the last poll, for LastError.
+type bmp280Health struct {
+	mutex sync.Mutex
+	err   error
+}
+
+func (h *bmp280Health) set(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.err = err
+}
+
+func (h *bmp280Health) get() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.err
+}
+
+// bmp280VerticalSpeed keeps the last altitude and its time, to compute
+// the vertical speed.
+type bmp280VerticalSpeed struct {
+	mutex sync.Mutex
+	alt   float32
+	time  time.Time
+}
+
+// update records the altitude at the given time, and returns the speed
+// since the previous altitude, if any.
+func (v *bmp280VerticalSpeed) update(alt float32, now time.Time) float32 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var speed float32
+	if elapsed := now.Sub(v.time).Seconds(); !v.time.IsZero() && elapsed > 0 {
+		speed = float32(float64(alt-v.alt) / elapsed)
+	}
+	v.alt, v.time = alt, now
+	return speed
+}
+
+// bmp280MovingAverage is the moving average of the last samples,
+// kept in a ring buffer. A nil bmp280MovingAverage does no averaging.
+type bmp280MovingAverage struct {
+	mutex   sync.Mutex
+	samples []float64
+	next    int
+	full    bool
+}
+
+func newBMP280MovingAverage(window int) *bmp280MovingAverage {
+	if window <= 1 {
+		return nil
+	}
+	return &bmp280MovingAverage{samples: make([]float64, window)}
+}
+
+// clone returns an empty moving average with the same window.
+func (a *bmp280MovingAverage) clone() *bmp280MovingAverage {
+	if a == nil {
+		return nil
+	}
+	return newBMP280MovingAverage(len(a.samples))
+}
+
+// add adds a sample, and returns the average of the samples in the window.
+func (a *bmp280MovingAverage) add(val float64) float64 {
+	if a == nil {
+		return val
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.samples[a.next] = val
+	a.next = (a.next + 1) % len(a.samples)
+	if a.next == 0 {
+		a.full = true
+	}
+	n := a.next
+	if a.full {
+		n = len(a.samples)
+	}
+	sum := 0.0
+	for _, s := range a.samples[:n] {
+		sum += s
+	}
+	return sum / float64(n)
+}
+
+// bmp280KalmanFilter is a one dimensional Kalman filter, estimating a
+// constant value disturbed by the process noise. A nil bmp280KalmanFilter
+// does no filtering.
+type bmp280KalmanFilter struct {
+	mutex      sync.Mutex
+	q          float64
+	r          float64
+	estimate   float64
+	covariance float64
+	started    bool
+}
+
+func newBMP280KalmanFilter(q float64, r float64) *bmp280KalmanFilter {
+	if q <= 0 || r <= 0 {
+		return nil
+	}
+	return &bmp280KalmanFilter{q: q, r: r}
+}
+
+// clone returns a filter with the same variances, waiting for its first sample.
+func (f *bmp280KalmanFilter) clone() *bmp280KalmanFilter {
+	if f == nil {
+		return nil
+	}
+	return newBMP280KalmanFilter(f.q, f.r)
+}
+
+// add updates the estimate with a measurement, and returns it.
+func (f *bmp280KalmanFilter) add(val float64) float64 {
+	if f == nil {
+		return val
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.started {
+		f.estimate, f.covariance, f.started = val, f.r, true
+		return val
+	}
+	f.covariance += f.q
+	gain := f.covariance / (f.covariance + f.r)
+	f.estimate += gain * (val - f.estimate)
+	f.covariance *= 1 - gain
+	return f.estimate
+}
+
+// bmp280MeasurementDuration returns the maximum duration of a measurement,
+// with a term of 2.3ms per sample, and 0.575ms per pressure or humidity measurement.
+func bmp280MeasurementDuration(temp BMP280Oversampling, others ...BMP280Oversampling) time.Duration {
+	d := 1250*time.Microsecond + time.Duration(temp.ratio())*2300*time.Microsecond
+	for _, o := range others {
+		if o != BMP280OversamplingSkip {
+			d += time.Duration(o.ratio())*2300*time.Microsecond + 575*time.Microsecond
+		}
+	}
+	return d
+}
+
+// bmp280CheckPressure returns an error if the compensated pressure is not a
+// number or outside the operating range of the sensor, which happens for
+// example with a disconnected sensor.
+func bmp280CheckPressure(press float64) error {
+	if math.IsNaN(press) || press < bmp280MinPressure || press > bmp280MaxPressure {
+		return fmt.Errorf("%w, pressure %.2f Pa", ErrBMP280OutOfRange, press)
+	}
+	return nil
+}
+
+// bmp280Altitude converts a pressure to an altitude relative to the given sea level pressure.
+func bmp280Altitude(press float32, seaLevel float32) float32 {
+	return float32(44330.0 * (1.0 - math.Pow(float64(press/seaLevel), 1/5.255)))
+}
+
+// bmp280HypsometricAltitude converts a pressure to an altitude relative to the
+// given sea level pressure, at the given temperature in celsius degrees.
+func bmp280HypsometricAltitude(press float64, seaLevel float64, temp float64) float32 {
+	return float32((math.Pow(seaLevel/press, 1/5.257) - 1.0) * (temp + 273.15) / 0.0065)
+}
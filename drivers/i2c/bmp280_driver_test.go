@@ -0,0 +1,2270 @@
+package i2c
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/gobottest"
+	"gobot.io/x/gobot/sysfs"
+)
+
+var _ gobot.Driver = (*BMP280Driver)(nil)
+
+// --------- HELPERS
+func initTestBMP280Driver() (driver *BMP280Driver) {
+	driver, _ = initTestBMP280DriverWithStubbedAdaptor()
+	return
+}
+
+func initTestBMP280DriverWithStubbedAdaptor() (*BMP280Driver, *i2cTestAdaptor) {
+	adaptor := newI2cTestAdaptor()
+	return NewBMP280Driver(adaptor), adaptor
+}
+
+// bmp280TestReadImpl returns a read stub answering with the values of the
+// datasheet compensation example (adc_T = 519888, adc_P = 415148).
+func bmp280TestReadImpl(adaptor *i2cTestAdaptor) func([]byte) (int, error) {
+	return func(b []byte) (int, error) {
+		buf := new(bytes.Buffer)
+		switch adaptor.written[len(adaptor.written)-1] {
+		case bmp280RegisterChipID:
+			buf.WriteByte(bmp280ChipID)
+		case bmp280RegisterVersion:
+			buf.WriteByte(0x01)
+		case bmp280RegisterCalib00:
+			binary.Write(buf, binary.LittleEndian, uint16(27504))
+			binary.Write(buf, binary.LittleEndian, int16(26435))
+			binary.Write(buf, binary.LittleEndian, int16(-1000))
+			binary.Write(buf, binary.LittleEndian, uint16(36477))
+			binary.Write(buf, binary.LittleEndian, int16(-10685))
+			binary.Write(buf, binary.LittleEndian, int16(3024))
+			binary.Write(buf, binary.LittleEndian, int16(2855))
+			binary.Write(buf, binary.LittleEndian, int16(140))
+			binary.Write(buf, binary.LittleEndian, int16(-7))
+			binary.Write(buf, binary.LittleEndian, int16(15500))
+			binary.Write(buf, binary.LittleEndian, int16(-14600))
+			binary.Write(buf, binary.LittleEndian, int16(6000))
+		case bmp280RegisterPressureData:
+			buf.Write([]byte{0x65, 0x5a, 0xc0, 0x7e, 0xed, 0x00})
+		case bmp280RegisterTempData:
+			buf.Write([]byte{0x7e, 0xed, 0x00})
+		case bmp280RegisterStatus:
+			buf.WriteByte(0x00)
+		}
+		copy(b, buf.Bytes())
+		return buf.Len(), nil
+	}
+}
+
+// bmp280TestCalibration are the calibration coefficients of the datasheet compensation example.
+var bmp280TestCalibration = BMP280CalibrationCoefficients{
+	T1: 27504, T2: 26435, T3: -1000,
+	P1: 36477, P2: -10685, P3: 3024, P4: 2855, P5: 140, P6: -7, P7: 15500, P8: -14600, P9: 6000,
+}
+
+// --------- TESTS
+
+func TestNewBMP280Driver(t *testing.T) {
+	// Does it return a pointer to an instance of BMP280Driver?
+	var bmp280 interface{} = NewBMP280Driver(newI2cTestAdaptor())
+	_, ok := bmp280.(*BMP280Driver)
+	if !ok {
+		t.Errorf("NewBMP280Driver() should have returned a *BMP280Driver")
+	}
+}
+
+func TestBMP280Driver(t *testing.T) {
+	bmp280 := initTestBMP280Driver()
+	gobottest.Refute(t, bmp280.Connection(), nil)
+}
+
+func TestBMP280DriverStart(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, bmp280.chipID, byte(bmp280ChipID))
+}
+
+func TestBMP280DriverStartChipID(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		b[0] = 0x55
+		return 1, nil
+	}
+	err := bmp280.Start()
+	gobottest.Assert(t, err.Error(), "BMP280: unexpected chip id 0x55, not a BMP280")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280BadChipID), true)
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterChipID})
+}
+
+func TestBMP280DriverHalt(t *testing.T) {
+	bmp280 := initTestBMP280Driver()
+
+	gobottest.Assert(t, bmp280.Halt(), nil)
+}
+
+func TestBMP280DriverMeasurements(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	pressure, err := bmp280.Pressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, pressure, float32(100653.26))
+	alt, err := bmp280.Altitude()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, alt, float32(56.07641))
+	alt, err = bmp280.AltitudeCompensated()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, alt, float32(58.090855))
+}
+
+func TestBMP280HypsometricAltitude(t *testing.T) {
+	gobottest.Assert(t, bmp280HypsometricAltitude(101325, 101325, 15), float32(0))
+	// at the 15 °C of the standard atmosphere, both formulas nearly agree.
+	alt := bmp280HypsometricAltitude(100000, 101325, 15)
+	gobottest.Assert(t, math.Abs(float64(alt-bmp280Altitude(100000, 101325))) < 1, true)
+	// warmer air is less dense, the same pressure drop is a larger height.
+	gobottest.Assert(t, bmp280HypsometricAltitude(100000, 101325, 30) > alt, true)
+}
+
+func TestBMP280DriverSetName(t *testing.T) {
+	b := initTestBMP280Driver()
+	b.SetName("TESTME")
+	gobottest.Assert(t, b.Name(), "TESTME")
+}
+
+func TestBMP280DriverWithName(t *testing.T) {
+	b := NewBMP280Driver(newI2cTestAdaptor(), WithBMP280Name("outdoor"), WithBus(2))
+	gobottest.Assert(t, b.Name(), "outdoor")
+	gobottest.Assert(t, b.GetBusOrDefault(1), 2)
+
+	bme := NewBME280Driver(newI2cTestAdaptor(), WithBMP280Name("indoor"))
+	gobottest.Assert(t, bme.Name(), "indoor")
+}
+
+func TestBMP280DriverInvalidAddress(t *testing.T) {
+	var tests = []struct {
+		address int
+		err     string
+	}{
+		{address: 0xec, err: "BMP280: invalid i2c address 0xEC, use the 7 bit address 0x76"},
+		{address: 0x02, err: "BMP280: invalid i2c address 0x02, expected 0x03 to 0x77"},
+		{address: 0x100, err: "BMP280: invalid i2c address 0x100, expected 0x03 to 0x77"},
+	}
+	for _, tt := range tests {
+		adaptor := newI2cTestAdaptor()
+		bmp280 := NewBMP280Driver(adaptor, WithAddress(tt.address))
+		err := bmp280.Start()
+		gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidAddress), true)
+		gobottest.Assert(t, err.Error(), tt.err)
+		gobottest.Assert(t, len(adaptor.written), 0)
+
+		// with a custom connection as well.
+		bmp280 = NewBMP280Driver(nil, WithBMP280Connection(adaptor), WithAddress(tt.address))
+		gobottest.Assert(t, bmp280.Start().Error(), tt.err)
+		gobottest.Assert(t, len(adaptor.written), 0)
+	}
+
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	err := bmp280.SetAddress(0xee)
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidAddress), true)
+	gobottest.Assert(t, bmp280.GetAddressOrDefault(bmp280Address), bmp280Address)
+}
+
+func TestBMP280DriverClone(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280 := NewBMP280Driver(adaptor, WithBus(2), WithBMP280Name("template"),
+		WithBMP280PressureOversampling(BMP280Oversampling16x), WithBMP280MovingAverage(4))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	// e.g. after a timed out transaction of the template.
+	bmp280.needsReset = true
+
+	clone := bmp280.Clone(WithAddress(0x77))
+	gobottest.Assert(t, clone.needsReset, false)
+	gobottest.Refute(t, clone.Name(), "template")
+	gobottest.Assert(t, clone.GetBusOrDefault(1), 2)
+	gobottest.Assert(t, clone.GetAddressOrDefault(bmp280Address), 0x77)
+	gobottest.Assert(t, bmp280.GetAddressOrDefault(bmp280Address), bmp280Address)
+	gobottest.Assert(t, clone.pressOversampling, BMP280Oversampling16x)
+	gobottest.Assert(t, len(clone.pressAverage.samples), 4)
+	gobottest.Assert(t, clone.pressAverage != bmp280.pressAverage, true)
+	gobottest.Assert(t, clone.tpc != bmp280.tpc, true)
+	gobottest.Assert(t, clone.MeasurementDuration(), bmp280.MeasurementDuration())
+
+	_, err := clone.Temperature()
+	gobottest.Assert(t, err, ErrBMP280NotStarted)
+	gobottest.Assert(t, clone.Start(), nil)
+	gobottest.Assert(t, *clone.tpc, bmp280TestCalibration)
+	result := clone.Command("Temperature")(map[string]interface{}{})
+	gobottest.Assert(t, result.(map[string]interface{})["val"], float32(25.082478))
+}
+
+func TestBMP280DriverWithOptions(t *testing.T) {
+	lowPower := []func(Config){
+		WithBMP280ForcedMode(),
+		WithBMP280PressureOversampling(BMP280Oversampling1x),
+	}
+	highAccuracy := WithBMP280Options(
+		WithBMP280PressureOversampling(BMP280Oversampling16x),
+		WithBMP280IIRFilter(BMP280Filter16),
+	)
+	b := NewBMP280Driver(newI2cTestAdaptor(), WithBMP280Options(lowPower...), highAccuracy, WithAddress(0x77))
+	gobottest.Assert(t, b.forcedMode, true)
+	gobottest.Assert(t, b.pressOversampling, BMP280Oversampling16x)
+	gobottest.Assert(t, b.filter, BMP280Filter16)
+	gobottest.Assert(t, b.GetAddressOrDefault(bmp280Address), 0x77)
+
+	bme := NewBME280Driver(newI2cTestAdaptor(), highAccuracy)
+	gobottest.Assert(t, bme.filter, BMP280Filter16)
+}
+
+func TestBMP280DriverOptions(t *testing.T) {
+	b := NewBMP280Driver(newI2cTestAdaptor(), WithBus(2))
+	gobottest.Assert(t, b.GetBusOrDefault(1), 2)
+}
+
+func TestBMP280DriverSeaLevelPressure(t *testing.T) {
+	b := NewBMP280Driver(newI2cTestAdaptor())
+	gobottest.Assert(t, b.seaLevelPressure, float32(101325))
+
+	b = NewBMP280Driver(newI2cTestAdaptor(), WithBMP280SeaLevelPressure(100000))
+	gobottest.Assert(t, b.seaLevelPressure, float32(100000))
+
+	b.SetSeaLevelPressure(102000)
+	gobottest.Assert(t, b.seaLevelPressure, float32(102000))
+}
+
+func TestBMP280DriverStartPowerMode(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-6:], []byte{
+		bmp280RegisterControl, 0x24,
+		bmp280RegisterConfig, 0x00,
+		bmp280RegisterControl, 0x27,
+	})
+}
+
+func TestBMP280DriverSetPowerMode(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeForced), nil)
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterControl, 0x25})
+	gobottest.Assert(t, bmp280.powerMode, BMP280PowerModeForced)
+
+	adaptor.i2cWriteImpl = func([]byte) (int, error) {
+		return 0, errors.New("write error")
+	}
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeSleep), errors.New("write error"))
+	gobottest.Assert(t, bmp280.powerMode, BMP280PowerModeForced)
+}
+
+func TestBMP280DriverOversampling(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor,
+		WithBMP280TemperatureOversampling(BMP280Oversampling2x),
+		WithBMP280PressureOversampling(BMP280Oversampling16x))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-2:], []byte{bmp280RegisterControl, 0x57})
+}
+
+func TestBMP280DriverIIRFilter(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280IIRFilter(BMP280Filter16))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-6:], []byte{
+		bmp280RegisterControl, 0x24,
+		bmp280RegisterConfig, 0x10,
+		bmp280RegisterControl, 0x27,
+	})
+}
+
+func TestBMP280DriverStandbyTime(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor,
+		WithBMP280StandbyTime(BMP280Standby1000ms),
+		WithBMP280IIRFilter(BMP280Filter4))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-4:len(adaptor.written)-2], []byte{
+		bmp280RegisterConfig, 0xa8,
+	})
+}
+
+func TestBMP280DriverReset(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bmp280.Reset(), nil)
+	gobottest.Assert(t, adaptor.written[:3], []byte{bmp280RegisterReset, bmp280CmdReset, bmp280RegisterChipID})
+
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		b[0] = 0xff
+		return 1, nil
+	}
+	err := bmp280.Reset()
+	gobottest.Assert(t, err.Error(), "BMP280: unexpected chip id 0xFF, not a BMP280")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280BadChipID), true)
+}
+
+func TestBMP280DriverFirstMeasurement(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	// the data registers hold their reset value until the first measurement
+	// in normal mode completes.
+	var normal time.Time
+	adaptor.i2cWriteImpl = func(b []byte) (int, error) {
+		if len(adaptor.written) > 1 && adaptor.written[len(adaptor.written)-2] == bmp280RegisterControl &&
+			b[0]&0x03 == byte(BMP280PowerModeNormal) {
+			normal = time.Now()
+		}
+		return len(b), nil
+	}
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterPressureData &&
+			time.Since(normal) < bmp280.MeasurementDuration() {
+			return copy(b, []byte{0x80, 0x00, 0x00, 0x80, 0x00, 0x00}), nil
+		}
+		return readImpl(b)
+	}
+	gobottest.Assert(t, bmp280.Start(), nil)
+	_, _, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, bmp280.Reset(), nil)
+	_, _, err = bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+}
+
+func TestBMP280DriverTemperatureAndPressure(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	adaptor.written = []byte{}
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterPressureData})
+
+	adaptor.i2cWriteImpl = func([]byte) (int, error) {
+		return 0, errors.New("write error")
+	}
+	_, _, err = bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, errors.New("write error"))
+}
+
+func TestBMP280DriverShortRead(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		return 3, nil
+	}
+	_, err := bmp280.Pressure()
+	gobottest.Assert(t, err.Error(), "BMP280: short read, expected 6 bytes, read 3")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280ShortRead), true)
+}
+
+func TestBMP280DriverCompensation(t *testing.T) {
+	bmp280 := initTestBMP280Driver()
+	*bmp280.tpc = bmp280TestCalibration
+
+	var tests = []struct {
+		rawTemp, rawPress int32
+		temp              float64
+		tFine             int32
+		press             float64
+	}{
+		{rawTemp: 519888, rawPress: 415148, temp: 25.08247793081682, tFine: 128422, press: 100653.25814481472},
+		{rawTemp: 400000, rawPress: 300000, temp: -12.64360672980547, tFine: -64735, press: 113634.8594198012},
+		{rawTemp: 600000, rawPress: 500000, temp: 50.109787283465266, tFine: 256562, press: 89314.27700044958},
+	}
+	for _, tt := range tests {
+		temp, tFine := bmp280.calculateTemp(tt.rawTemp)
+		gobottest.Assert(t, temp, tt.temp)
+		gobottest.Assert(t, tFine, tt.tFine)
+		press, err := bmp280.calculatePress(tt.rawPress, tFine)
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, press, tt.press)
+	}
+}
+
+func TestCompensateBMP280(t *testing.T) {
+	// the raw readings and coefficients of the datasheet example, without a driver.
+	temp, tFine := CompensateBMP280Temperature(519888, bmp280TestCalibration)
+	gobottest.Assert(t, temp, 25.08247793081682)
+	gobottest.Assert(t, tFine, int32(128422))
+	press, err := CompensateBMP280Pressure(415148, tFine, bmp280TestCalibration)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, 100653.25814481472)
+	_, err = CompensateBMP280Pressure(415148, tFine, BMP280CalibrationCoefficients{})
+	gobottest.Assert(t, err.Error(), "BMP280: invalid calibration data, check wiring, pressure compensation divides by zero, dig_P1 0")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidCalibration), true)
+}
+
+func ExampleCompensateBMP280Pressure() {
+	// the calibration and raw readings of the datasheet compensation example.
+	c := BMP280CalibrationCoefficients{
+		T1: 27504, T2: 26435, T3: -1000,
+		P1: 36477, P2: -10685, P3: 3024, P4: 2855, P5: 140, P6: -7, P7: 15500, P8: -14600, P9: 6000,
+	}
+	temp, tFine := CompensateBMP280Temperature(519888, c)
+	press, err := CompensateBMP280Pressure(415148, tFine, c)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%.2f °C, %.2f Pa\n", temp, press)
+	// Output: 25.08 °C, 100653.26 Pa
+}
+
+func TestBMP280DriverPressureDivisionByZero(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	calibration := bmp280TestCalibration
+	calibration.P1 = 0
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280Calibration(calibration))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	_, err := bmp280.Pressure()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidCalibration), true)
+	gobottest.Assert(t, err.Error(), "BMP280: invalid calibration data, check wiring, pressure compensation divides by zero, dig_P1 0")
+	_, err = bmp280.Read()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidCalibration), true)
+	_, _, err = bmp280.FixedTemperatureAndPressure()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidCalibration), true)
+
+	// the temperature does not depend on dig_P1.
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+
+	WithBMP280IntegerCompensation()(bmp280)
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidCalibration), true)
+}
+
+func TestBMP280DriverFineTemperature(t *testing.T) {
+	bmp280 := initTestBMP280Driver()
+	*bmp280.tpc = bmp280TestCalibration
+
+	// worked example of the datasheet, section 8.1.
+	temp, tFine := bmp280.calculateTemp(519888)
+	gobottest.Assert(t, tFine, int32(128422))
+	gobottest.Assert(t, math.Round(temp*100)/100, 25.08)
+	press, _ := bmp280.calculatePress(415148, tFine)
+	gobottest.Assert(t, math.Abs(press-100653.27) < 0.02, true)
+}
+
+func TestBMP280DriverFixedCompensation(t *testing.T) {
+	bmp280 := initTestBMP280Driver()
+	*bmp280.tpc = bmp280TestCalibration
+
+	// input values of the worked example of the datasheet, section 8.1.
+	temp, tFine := bmp280.calculateTempFixed(519888)
+	gobottest.Assert(t, temp, int32(2508))
+	gobottest.Assert(t, tFine, int32(128422))
+	press, err := bmp280.calculatePressFixed(415148, tFine)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, uint32(25767233))
+
+	// both algorithms agree within rounding.
+	for rawTemp := int32(300000); rawTemp <= 700000; rawTemp += 25000 {
+		for rawPress := int32(250000); rawPress <= 550000; rawPress += 25000 {
+			tempF, tFineF := bmp280.calculateTemp(rawTemp)
+			temp, tFine := bmp280.calculateTempFixed(rawTemp)
+			gobottest.Assert(t, math.Abs(float64(temp)/100-tempF) <= 0.01, true)
+			gobottest.Assert(t, math.Abs(float64(tFine-tFineF)) <= 2, true)
+			pressF, _ := bmp280.calculatePress(rawPress, tFineF)
+			press, _ := bmp280.calculatePressFixed(rawPress, tFineF)
+			gobottest.Assert(t, math.Abs(float64(press)/256-pressF) < 0.1, true)
+		}
+	}
+}
+
+func TestBMP280DriverIntegerCompensation(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280IntegerCompensation())
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.08))
+	gobottest.Assert(t, press, float32(100653.25390625))
+
+	fixedTemp, fixedPress, err := bmp280.FixedTemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, fixedTemp, int32(2508))
+	gobottest.Assert(t, fixedPress, uint32(25767233))
+}
+
+func TestBMP280DriverRegisters(t *testing.T) {
+	regs := NewBMP280RegisterMap(map[byte]byte{0xd1: 0x12, 0xd2: 0x34})
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs))
+	_, err := bmp280.ReadRegisters(0xd1, 2)
+	gobottest.Assert(t, err, ErrBMP280NotStarted)
+	bmp280.transport = regs
+
+	data, err := bmp280.ReadRegisters(0xd1, 2)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, data, []byte{0x12, 0x34})
+	gobottest.Assert(t, bmp280.WriteRegister(0xf5, 0x10), nil)
+	gobottest.Assert(t, regs.Register(0xf5), uint8(0x10))
+
+	_, err = bmp280.ReadRegisters(0xff, 2)
+	gobottest.Assert(t, err.Error(), "BMP280: invalid register range 0xFF, 2 bytes")
+	_, err = bmp280.ReadRegisters(0x00, 0)
+	gobottest.Assert(t, err.Error(), "BMP280: invalid register range 0x00, 0 bytes")
+}
+
+func TestBMP280DriverReadRegistersLarge(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if len(b) == 0x100 {
+			return len(b), nil
+		}
+		return readImpl(b)
+	}
+	conn := &bmp280TestBlockConnection{i2cTestAdaptor: adaptor}
+	bmp280 := NewBMP280Driver(nil, WithBMP280Connection(conn))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	conn.regs = nil
+	conn.written = []byte{}
+
+	// more than a block read, written and read in two transactions.
+	data, err := bmp280.ReadRegisters(0x00, 0x100)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, len(data), 0x100)
+	gobottest.Assert(t, len(conn.regs), 0)
+	gobottest.Assert(t, conn.written, []byte{0x00})
+}
+
+func TestBMP280StandbyTimeDuration(t *testing.T) {
+	gobottest.Assert(t, BMP280Standby500us.duration(false), 500*time.Microsecond)
+	gobottest.Assert(t, BMP280Standby62500us.duration(false), 62500*time.Microsecond)
+	gobottest.Assert(t, BMP280Standby125ms.duration(false), 125*time.Millisecond)
+	gobottest.Assert(t, BMP280Standby1000ms.duration(true), time.Second)
+	gobottest.Assert(t, BMP280Standby4000ms.duration(false), 4*time.Second)
+	gobottest.Assert(t, BMP280Standby2000ms.duration(true), 10*time.Millisecond)
+	gobottest.Assert(t, BMP280Standby4000ms.duration(true), 20*time.Millisecond)
+}
+
+func TestBMP280DriverValidate(t *testing.T) {
+	// the defaults, with a standby time shorter than the measurements.
+	bmp280 := initTestBMP280Driver()
+	gobottest.Assert(t, bmp280.Validate(100), nil)
+	err := bmp280.Validate(157)
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidSettings), true)
+	gobottest.Assert(t, err.Error(), "BMP280: invalid settings, measurements of up to 6.425ms with "+
+		"temperature oversampling x1 and pressure oversampling x1 and the standby time of 500µs give 144.4 Hz instead of 157.0 Hz")
+
+	// the indoor navigation profile of the datasheet.
+	bmp280 = NewBMP280Driver(newI2cTestAdaptor(), WithBMP280TemperatureOversampling(BMP280Oversampling2x),
+		WithBMP280PressureOversampling(BMP280Oversampling16x), WithBMP280IIRFilter(BMP280Filter16))
+	gobottest.Assert(t, bmp280.Validate(20), nil)
+
+	bmp280 = NewBMP280Driver(newI2cTestAdaptor(), WithBMP280StandbyTime(BMP280Standby62500us))
+	gobottest.Assert(t, bmp280.Validate(14), nil)
+	gobottest.Refute(t, bmp280.Validate(15), nil)
+	gobottest.Assert(t, math.Round(bmp280.OutputDataRate()*1000)/1000, 14.509)
+
+	// the standby time does not apply to forced measurements.
+	bmp280 = NewBMP280Driver(newI2cTestAdaptor(), WithBMP280ForcedMode())
+	gobottest.Assert(t, bmp280.Validate(1000), nil)
+}
+
+func TestBMP280DriverVersion(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	gobottest.Assert(t, bmp280.Version(), uint8(0))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, bmp280.Version(), uint8(0x01))
+	gobottest.Assert(t, bmp280.Clone().Version(), uint8(0))
+}
+
+func TestBMP280DriverCompensationModel(t *testing.T) {
+	gobottest.Assert(t, initTestBMP280Driver().CompensationModel(), "bmp280-float-bosch-rev1.11")
+	bmp280 := NewBMP280Driver(newI2cTestAdaptor(), WithBMP280IntegerCompensation())
+	gobottest.Assert(t, bmp280.CompensationModel(), "bmp280-int-bosch-rev1.11")
+}
+
+func TestBMP280DriverIntegerCompensationPanic(t *testing.T) {
+	defer func() {
+		gobottest.Assert(t, recover(), "Trying to set integer compensation for non-BMP280Driver")
+	}()
+	NewBMP180Driver(newI2cTestAdaptor(), WithBMP280IntegerCompensation())
+}
+
+// bmp280TestHangingTransport blocks the reads of the data registers until released.
+type bmp280TestHangingTransport struct {
+	*BMP280RegisterMap
+	release chan struct{}
+}
+
+func (t bmp280TestHangingTransport) ReadRegisters(reg byte, data []byte) error {
+	if reg == bmp280RegisterPressureData {
+		<-t.release
+	}
+	return t.BMP280RegisterMap.ReadRegisters(reg, data)
+}
+
+func TestBMP280DriverTimeout(t *testing.T) {
+	transport := bmp280TestHangingTransport{
+		BMP280RegisterMap: NewBMP280RegisterMap(map[byte]byte{bmp280RegisterChipID: bmp280ChipID, bmp280RegisterCalib00: 0x70}),
+		release:           make(chan struct{}),
+	}
+	defer close(transport.release)
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(transport), WithBMP280Timeout(10*time.Millisecond),
+		WithBMP280ReadRetries(3, time.Millisecond))
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	start := time.Now()
+	_, err := bmp280.Pressure()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280TransactionTimeout), true)
+	// a stuck bus is not retried.
+	gobottest.Assert(t, time.Since(start) < 40*time.Millisecond, true)
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, err, ErrBMP280NeedsReset)
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeSleep), ErrBMP280NeedsReset)
+
+	gobottest.Assert(t, bmp280.Reset(), nil)
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+}
+
+func TestBMP280DriverTimeoutPanic(t *testing.T) {
+	defer func() {
+		gobottest.Assert(t, recover(), "Trying to set timeout for non-BMP280Driver")
+	}()
+	NewBMP180Driver(newI2cTestAdaptor(), WithBMP280Timeout(time.Second))
+}
+
+func TestBMP280DriverVerticalSpeed(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	speed, err := bmp280.VerticalSpeed()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, speed, float32(0))
+	gobottest.Assert(t, bmp280.climb.alt, float32(56.07641))
+
+	now := bmp280.climb.time
+	gobottest.Assert(t, bmp280.climb.update(61.07641, now.Add(2*time.Second)), float32(2.5))
+	gobottest.Assert(t, bmp280.climb.update(51.07641, now.Add(6*time.Second)), float32(-2.5))
+	// no speed without elapsed time.
+	gobottest.Assert(t, bmp280.climb.update(60, now.Add(6*time.Second)), float32(0))
+}
+
+func TestBMP280DriverConcurrentReads(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			temp, err := bmp280.Temperature()
+			gobottest.Assert(t, err, nil)
+			gobottest.Assert(t, temp, float32(25.082478))
+		}()
+		go func() {
+			defer wg.Done()
+			press, err := bmp280.Pressure()
+			gobottest.Assert(t, err, nil)
+			gobottest.Assert(t, press, float32(100653.26))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBMP280DriverReadRetries(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280ReadRetries(2, time.Millisecond))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	readImpl := adaptor.i2cReadImpl
+	failures := 2
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if failures > 0 {
+			failures--
+			return 0, errors.New("read error")
+		}
+		return readImpl(b)
+	}
+	press, err := bmp280.Pressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, float32(100653.26))
+
+	readErr := errors.New("read error")
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		return 0, readErr
+	}
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, err.Error(), "BMP280: read failed after 2 retries: read error")
+	gobottest.Assert(t, errors.Unwrap(err), readErr)
+}
+
+func TestBMP280DriverCommands(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	result := bmp280.Command("Temperature")(map[string]interface{}{})
+	gobottest.Assert(t, result.(map[string]interface{})["val"], float32(25.082478))
+	gobottest.Assert(t, result.(map[string]interface{})["err"], nil)
+
+	result = bmp280.Command("Pressure")(map[string]interface{}{})
+	gobottest.Assert(t, result.(map[string]interface{})["val"], float32(100653.26))
+
+	result = bmp280.Command("Altitude")(map[string]interface{}{})
+	gobottest.Assert(t, result.(map[string]interface{})["val"], float32(56.07641))
+
+	adaptor.i2cReadImpl = func([]byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	result = bmp280.Command("Pressure")(map[string]interface{}{})
+	gobottest.Assert(t, result.(map[string]interface{})["err"], errors.New("read error"))
+}
+
+func TestBMP280DriverPolling(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280PollInterval(time.Millisecond))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+
+	temps := make(chan interface{}, 1)
+	presses := make(chan interface{}, 1)
+	bmp280.On(bmp280.Event(BMP280TemperatureEvent), func(data interface{}) {
+		select {
+		case temps <- data:
+		default:
+		}
+	})
+	bmp280.On(bmp280.Event(BMP280PressureEvent), func(data interface{}) {
+		select {
+		case presses <- data:
+		default:
+		}
+	})
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	select {
+	case temp := <-temps:
+		gobottest.Assert(t, temp, float32(25.082478))
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("BMP280 Event \"temperature\" was not published")
+	}
+	select {
+	case press := <-presses:
+		gobottest.Assert(t, press, float32(100653.26))
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("BMP280 Event \"pressure\" was not published")
+	}
+	gobottest.Assert(t, bmp280.Halt(), nil)
+	gobottest.Assert(t, bmp280.halt, (chan bool)(nil))
+}
+
+func TestBMP280DriverPollingPressureSkipped(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280PollInterval(time.Millisecond),
+		WithBMP280PressureOversampling(BMP280OversamplingSkip))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+
+	temps := make(chan interface{}, 1)
+	errs := make(chan interface{}, 1)
+	bmp280.On(bmp280.Event(BMP280TemperatureEvent), func(data interface{}) {
+		select {
+		case temps <- data:
+		default:
+		}
+	})
+	bmp280.On(bmp280.Event(Error), func(data interface{}) {
+		select {
+		case errs <- data:
+		default:
+		}
+	})
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	select {
+	case temp := <-temps:
+		gobottest.Assert(t, temp, float32(25.082478))
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("BMP280 Event \"temperature\" was not published")
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("BMP280 Event \"error\" was published: %v", err)
+	default:
+	}
+	gobottest.Assert(t, bmp280.Healthy(), true)
+	gobottest.Assert(t, bmp280.Halt(), nil)
+}
+
+func TestBMP280DriverPollingError(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280PollInterval(time.Millisecond))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	errs := make(chan interface{}, 1)
+	bmp280.On(bmp280.Event(Error), func(data interface{}) {
+		select {
+		case errs <- data:
+		default:
+		}
+	})
+	bmp280.mutex.Lock()
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	bmp280.mutex.Unlock()
+
+	select {
+	case err := <-errs:
+		gobottest.Assert(t, err, errors.New("read error"))
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("BMP280 Event \"error\" was not published")
+	}
+	gobottest.Assert(t, bmp280.Halt(), nil)
+}
+
+func TestBMP280DriverHealthy(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280PollInterval(time.Millisecond))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Healthy(), false)
+	gobottest.Assert(t, bmp280.LastError(), ErrBMP280NotStarted)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, bmp280.Healthy(), true)
+
+	bmp280.mutex.Lock()
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	bmp280.mutex.Unlock()
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for bmp280.Healthy() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	gobottest.Assert(t, bmp280.Healthy(), false)
+	gobottest.Assert(t, bmp280.LastError(), errors.New("read error"))
+
+	gobottest.Assert(t, bmp280.Halt(), nil)
+	gobottest.Assert(t, bmp280.LastError(), ErrBMP280NotStarted)
+	gobottest.Assert(t, bmp280.Clone().LastError(), ErrBMP280NotStarted)
+}
+
+func TestBMP280DriverMetricSink(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	metrics := map[string]float64{}
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280PressureUnit(PressureUnitHectopascal),
+		WithBMP280MetricSink(func(name string, value float64) {
+			metrics[name] = value
+		}))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	_, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, len(metrics), 1)
+	gobottest.Assert(t, float32(metrics[BMP280MetricTemperature]), float32(25.082478))
+
+	_, err = bmp280.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, len(metrics), 2)
+	gobottest.Assert(t, float32(metrics[BMP280MetricPressure]), float32(100653.26))
+
+	// failed reads are not reported.
+	metrics = map[string]float64{}
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	_, _, err = bmp280.TemperatureAndPressure()
+	gobottest.Refute(t, err, nil)
+	gobottest.Assert(t, len(metrics), 0)
+}
+
+func TestTemperature(t *testing.T) {
+	var tests = []struct {
+		celsius, fahrenheit, kelvin float64
+	}{
+		{celsius: 0, fahrenheit: 32, kelvin: 273.15},
+		{celsius: 100, fahrenheit: 212, kelvin: 373.15},
+		{celsius: -40, fahrenheit: -40, kelvin: 233.15},
+		{celsius: -273.15, fahrenheit: -459.67, kelvin: 0},
+	}
+	for _, tt := range tests {
+		temp := Temperature(tt.celsius)
+		gobottest.Assert(t, temp.Celsius(), tt.celsius)
+		gobottest.Assert(t, math.Abs(temp.Fahrenheit()-tt.fahrenheit) < 1e-9, true)
+		gobottest.Assert(t, math.Abs(temp.Kelvin()-tt.kelvin) < 1e-9, true)
+		gobottest.Assert(t, temp.In(TemperatureUnitFahrenheit), temp.Fahrenheit())
+	}
+}
+
+func TestPressure(t *testing.T) {
+	press := Pressure(101325)
+	gobottest.Assert(t, press.Pa(), 101325.0)
+	gobottest.Assert(t, press.HPa(), 1013.25)
+	gobottest.Assert(t, math.Abs(press.MmHg()-760) < 1e-3, true)
+	gobottest.Assert(t, math.Abs(press.InHg()-29.9213) < 1e-4, true)
+	gobottest.Assert(t, press.In(PressureUnitHectopascal), 1013.25)
+	gobottest.Assert(t, press.In(PressureUnitPascal), 101325.0)
+}
+
+func TestBMP280DriverTypedTemperatureAndPressure(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280TemperatureUnit(TemperatureUnitKelvin),
+		WithBMP280PressureUnit(PressureUnitHectopascal), WithBMP280RoundTo(1))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	temp, press, err := bmp280.TypedTemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, float32(temp.Celsius()), float32(25.082478))
+	gobottest.Assert(t, float32(press.Pa()), float32(100653.26))
+	gobottest.Assert(t, float32(press.HPa()), float32(1006.5326))
+
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	_, _, err = bmp280.TypedTemperatureAndPressure()
+	gobottest.Assert(t, err, errors.New("read error"))
+}
+
+func TestBMP280DriverTemperatureUnit(t *testing.T) {
+	var tests = []struct {
+		unit TemperatureUnit
+		temp float32
+	}{
+		{unit: TemperatureUnitCelsius, temp: 25.082478},
+		{unit: TemperatureUnitFahrenheit, temp: 77.14846},
+		{unit: TemperatureUnitKelvin, temp: 298.23248},
+	}
+	for _, tt := range tests {
+		adaptor := newI2cTestAdaptor()
+		bmp280 := NewBMP280Driver(adaptor, WithBMP280TemperatureUnit(tt.unit))
+		adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+		bmp280.Start()
+		temp, err := bmp280.Temperature()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, temp, tt.temp)
+		temp, err = bmp280.TemperatureCelsius()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, temp, float32(25.082478))
+	}
+}
+
+func TestBMP280DriverPressureUnit(t *testing.T) {
+	var tests = []struct {
+		unit  PressureUnit
+		press float32
+	}{
+		{unit: PressureUnitPascal, press: 100653.26},
+		{unit: PressureUnitHectopascal, press: 1006.5326},
+		{unit: PressureUnitMillimeterOfMercury, press: 754.9614},
+		{unit: PressureUnitInchOfMercury, press: 29.722887},
+	}
+	for _, tt := range tests {
+		adaptor := newI2cTestAdaptor()
+		bmp280 := NewBMP280Driver(adaptor, WithBMP280PressureUnit(tt.unit))
+		adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+		bmp280.Start()
+		press, err := bmp280.Pressure()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, press, tt.press)
+		alt, err := bmp280.Altitude()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, alt, float32(56.07641))
+	}
+}
+
+func TestSetDefaultUnits(t *testing.T) {
+	existing := initTestBMP280Driver()
+	SetDefaultUnits(TemperatureUnitFahrenheit, PressureUnitHectopascal)
+	defer SetDefaultUnits(TemperatureUnitCelsius, PressureUnitPascal)
+
+	bmp280 := initTestBMP280Driver()
+	gobottest.Assert(t, bmp280.tempUnit, TemperatureUnitFahrenheit)
+	gobottest.Assert(t, bmp280.pressUnit, PressureUnitHectopascal)
+	bme280 := NewBME280Driver(newI2cTestAdaptor(), WithBMP280PressureUnit(PressureUnitInchOfMercury))
+	gobottest.Assert(t, bme280.tempUnit, TemperatureUnitFahrenheit)
+	gobottest.Assert(t, bme280.pressUnit, PressureUnitInchOfMercury)
+	gobottest.Assert(t, existing.tempUnit, TemperatureUnitCelsius)
+	gobottest.Assert(t, existing.pressUnit, PressureUnitPascal)
+}
+
+func TestBMP280DriverNotStarted(t *testing.T) {
+	bmp280 := initTestBMP280Driver()
+	_, err := bmp280.Temperature()
+	gobottest.Assert(t, err, errors.New("BMP280: driver not started"))
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, err, errors.New("BMP280: driver not started"))
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeSleep), errors.New("BMP280: driver not started"))
+}
+
+func TestBMP280DriverStatus(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		b[0] = 0x09
+		return 1, nil
+	}
+	measuring, updating, err := bmp280.Status()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, measuring, true)
+	gobottest.Assert(t, updating, true)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-1], byte(bmp280RegisterStatus))
+
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		b[0] = 0x00
+		return 1, nil
+	}
+	measuring, err = bmp280.Measuring()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, measuring, false)
+}
+
+func TestBMP280DriverForcedModeWait(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280MeasurementTimeout(50*time.Millisecond))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	polls := 0
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		polls++
+		if polls < 3 {
+			b[0] = bmp280StatusMeasuring
+		} else {
+			b[0] = 0x00
+		}
+		return 1, nil
+	}
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeForced), nil)
+	gobottest.Assert(t, polls, 3)
+
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		b[0] = bmp280StatusMeasuring
+		return 1, nil
+	}
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeForced), errors.New("BMP280: measurement timed out"))
+}
+
+func TestBMP280DriverCalibrationCoefficients(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	coefficients := bmp280.CalibrationCoefficients()
+	gobottest.Assert(t, coefficients, bmp280TestCalibration)
+
+	// the returned value is a copy.
+	coefficients.T1 = 0
+	gobottest.Assert(t, bmp280.tpc.T1, uint16(27504))
+}
+
+func TestBMP280DriverCalibrationParse(t *testing.T) {
+	// little endian blob with unsigned values above 32767 and negative signed values.
+	blob := []byte{
+		0x40, 0x9c, 0xc7, 0xcf, 0xe8, 0x03,
+		0x50, 0xc3, 0x43, 0xd6, 0xd0, 0x0b, 0xd9, 0xf4, 0x8c, 0x00,
+		0xf9, 0xff, 0x74, 0xc3, 0xf8, 0xc6, 0x70, 0x17,
+	}
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterCalib00 {
+			return copy(b, blob), nil
+		}
+		return readImpl(b)
+	}
+	gobottest.Assert(t, bmp280.Start(), nil)
+	c := bmp280.CalibrationCoefficients()
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"T1", c.T1, uint16(40000)},
+		{"T2", c.T2, int16(-12345)},
+		{"T3", c.T3, int16(1000)},
+		{"P1", c.P1, uint16(50000)},
+		{"P2", c.P2, int16(-10685)},
+		{"P3", c.P3, int16(3024)},
+		{"P4", c.P4, int16(-2855)},
+		{"P5", c.P5, int16(140)},
+		{"P6", c.P6, int16(-7)},
+		{"P7", c.P7, int16(-15500)},
+		{"P8", c.P8, int16(-14600)},
+		{"P9", c.P9, int16(6000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gobottest.Assert(t, tt.got, tt.want)
+		})
+	}
+}
+
+func TestBMP280DriverInvalidCalibration(t *testing.T) {
+	for _, fill := range []byte{0x00, 0xff} {
+		bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+		readImpl := bmp280TestReadImpl(adaptor)
+		adaptor.i2cReadImpl = func(b []byte) (int, error) {
+			if adaptor.written[len(adaptor.written)-1] == bmp280RegisterCalib00 {
+				for i := range b {
+					b[i] = fill
+				}
+				return len(b), nil
+			}
+			return readImpl(b)
+		}
+		gobottest.Assert(t, bmp280.Start(), errors.New("BMP280: invalid calibration data, check wiring"))
+	}
+}
+
+func TestBMP280DriverCustomConnection(t *testing.T) {
+	conn := newI2cTestAdaptor()
+	conn.i2cReadImpl = bmp280TestReadImpl(conn)
+	bmp280 := NewBMP280Driver(nil, WithBMP280Connection(conn))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+}
+
+type bmp280TestBlockConnection struct {
+	*i2cTestAdaptor
+	regs []uint8
+}
+
+func (c *bmp280TestBlockConnection) ReadI2cBlockData(reg uint8, b []byte) (n int, err error) {
+	if len(b) > 32 {
+		return 0, errors.New("block too large")
+	}
+	c.regs = append(c.regs, reg)
+	c.written = append(c.written, reg)
+	return c.i2cReadImpl(b)
+}
+
+// bmp280TestUnsupportedConnection has an adapter without I2C block reads.
+type bmp280TestUnsupportedConnection struct {
+	*i2cTestAdaptor
+}
+
+func (c *bmp280TestUnsupportedConnection) ReadI2cBlockData(uint8, []byte) (int, error) {
+	return 0, sysfs.ErrI2cBlockReadNotSupported
+}
+
+func TestBMP280DriverWriteAndRead(t *testing.T) {
+	connections := map[string]func(*i2cTestAdaptor) Connection{
+		// as the firmata one, with a ReadBlockData in two transactions.
+		"block data":    func(a *i2cTestAdaptor) Connection { return a },
+		"no block data": func(a *i2cTestAdaptor) Connection { return struct{ Connection }{a} },
+		"not supported": func(a *i2cTestAdaptor) Connection { return &bmp280TestUnsupportedConnection{a} },
+	}
+	for name, connection := range connections {
+		t.Run(name, func(t *testing.T) {
+			adaptor := newI2cTestAdaptor()
+			readImpl := bmp280TestReadImpl(adaptor)
+			var lengths []int
+			adaptor.i2cReadImpl = func(b []byte) (int, error) {
+				lengths = append(lengths, len(b))
+				return readImpl(b)
+			}
+			bmp280 := NewBMP280Driver(nil, WithBMP280Connection(connection(adaptor)))
+			gobottest.Assert(t, bmp280.Start(), nil)
+			adaptor.written = []byte{}
+			lengths = nil
+			temp, press, err := bmp280.TemperatureAndPressure()
+			gobottest.Assert(t, err, nil)
+			gobottest.Assert(t, temp, float32(25.082478))
+			gobottest.Assert(t, press, float32(100653.26))
+			gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterPressureData})
+			gobottest.Assert(t, lengths, []int{6})
+		})
+	}
+}
+
+func TestBMP280DriverReadI2cBlockData(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	conn := &bmp280TestBlockConnection{i2cTestAdaptor: adaptor}
+	bmp280 := NewBMP280Driver(nil, WithBMP280Connection(conn))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	conn.written = []byte{}
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+	gobottest.Assert(t, conn.regs, []uint8{bmp280RegisterChipID, bmp280RegisterVersion, bmp280RegisterCalib00, bmp280RegisterPressureData})
+
+	conn.i2cReadImpl = func([]byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, err, errors.New("read error"))
+}
+
+type bmp280TestConnector struct {
+	*i2cTestAdaptor
+	address int
+	bus     int
+}
+
+func (c *bmp280TestConnector) GetConnection(address int, bus int) (Connection, error) {
+	c.address = address
+	c.bus = bus
+	if address == 0x10 {
+		return nil, errors.New("no device")
+	}
+	return c.i2cTestAdaptor, nil
+}
+
+// bmp280TestBareConnector is a Connector, but not a gobot.Connection.
+type bmp280TestBareConnector struct {
+	conn Connection
+}
+
+func (c bmp280TestBareConnector) GetConnection(address int, bus int) (Connection, error) {
+	return c.conn, nil
+}
+
+func (c bmp280TestBareConnector) GetDefaultBus() int {
+	return 0
+}
+
+func TestBMP280DriverConnectionNotGobotConnection(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280 := NewBMP280Driver(bmp280TestBareConnector{conn: adaptor})
+	gobottest.Assert(t, bmp280.Connection(), nil)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, NewBMP280Driver(nil).Connection(), nil)
+}
+
+func TestBMP280DriverStartTwice(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	connector := &bmp280TestConnector{i2cTestAdaptor: adaptor}
+	var mutex sync.Mutex
+	var controls []byte
+	bmp280 := NewBMP280Driver(connector, WithBMP280PollInterval(time.Hour),
+		WithBMP280Trace(func(addr byte, dir string, data []byte, _ error) {
+			if addr == bmp280RegisterControl && dir == "write" {
+				mutex.Lock()
+				controls = append(controls, data[0])
+				mutex.Unlock()
+			}
+		}))
+	polled := make(chan interface{}, 1)
+	bmp280.Once(bmp280.Event(BMP280PressureEvent), func(data interface{}) {
+		polled <- data
+	})
+	gobottest.Assert(t, bmp280.Start(), nil)
+	halt := bmp280.halt
+
+	// the first poll accesses the bus right after Start.
+	select {
+	case <-polled:
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("BMP280 Event \"pressure\" was not published")
+	}
+	adaptor.written = []byte{}
+	connector.address = 0
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, len(adaptor.written), 0)
+	gobottest.Assert(t, connector.address, 0)
+	gobottest.Assert(t, bmp280.halt, halt)
+
+	// started again after Halt, in normal mode.
+	gobottest.Assert(t, bmp280.Halt(), nil)
+	gobottest.Assert(t, bmp280.powerMode, BMP280PowerModeNormal)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, connector.address, 0x76)
+	mutex.Lock()
+	// the config register is written in sleep mode on every Start.
+	gobottest.Assert(t, controls, []byte{0x24, 0x27, 0x24, 0x24, 0x27})
+	mutex.Unlock()
+	gobottest.Assert(t, bmp280.Halt(), nil)
+}
+
+func TestBMP280DriverDefaultAddress(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	connector := &bmp280TestConnector{i2cTestAdaptor: adaptor}
+	gobottest.Assert(t, NewBMP280Driver(connector).Start(), nil)
+	gobottest.Assert(t, connector.address, 0x76)
+
+	gobottest.Assert(t, NewBMP280Driver(connector, WithAddress(0x77)).Start(), nil)
+	gobottest.Assert(t, connector.address, 0x77)
+}
+
+func TestBMP280DriverAddressDetection(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	connector := &bmp280TestConnector{i2cTestAdaptor: adaptor}
+	readImpl := bmp280TestReadImpl(adaptor)
+	present := 0x77
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if connector.address != present {
+			return 0, errors.New("no device")
+		}
+		return readImpl(b)
+	}
+
+	// without detection, only the default address is tried.
+	gobottest.Assert(t, NewBMP280Driver(connector).Start(), errors.New("no device"))
+	gobottest.Assert(t, connector.address, 0x76)
+
+	bmp280 := NewBMP280Driver(connector, WithBMP280AddressDetection())
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, connector.address, 0x77)
+	gobottest.Assert(t, bmp280.GetAddressOrDefault(bmp280Address), 0x77)
+	_, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+
+	present = 0x76
+	bmp280 = NewBMP280Driver(connector, WithAddress(0x77), WithBMP280AddressDetection())
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, bmp280.GetAddressOrDefault(bmp280Address), 0x76)
+
+	present = 0x10
+	bmp280 = NewBMP280Driver(connector, WithBMP280AddressDetection())
+	gobottest.Assert(t, bmp280.Start(), errors.New("no device"))
+	gobottest.Assert(t, connector.address, 0x77)
+}
+
+func TestBMP280DriverDefaultBus(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	connector := &bmp280TestConnector{i2cTestAdaptor: adaptor}
+	options := []func(Config){WithBus(3), WithBus(BusNotInitialized)}
+	gobottest.Assert(t, NewBMP280Driver(connector, options...).Start(), nil)
+	gobottest.Assert(t, connector.bus, 0)
+}
+
+func TestBMP280DriverSetAddressAndBus(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	connector := &bmp280TestConnector{i2cTestAdaptor: adaptor}
+	bmp280 := NewBMP280Driver(connector)
+
+	// not started yet, only the config changes.
+	gobottest.Assert(t, bmp280.SetAddress(0x77), nil)
+	gobottest.Assert(t, len(adaptor.written), 0)
+
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, connector.address, 0x77)
+
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bmp280.SetAddress(0x76), nil)
+	gobottest.Assert(t, connector.address, 0x76)
+	gobottest.Assert(t, adaptor.written[0], byte(bmp280RegisterChipID))
+
+	gobottest.Assert(t, bmp280.SetBus(1), nil)
+	gobottest.Assert(t, connector.bus, 1)
+
+	gobottest.Assert(t, bmp280.SetAddress(0x10), errors.New("no device"))
+}
+
+func TestBMP280DriverConcurrentReset(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			press, err := bmp280.Pressure()
+			gobottest.Assert(t, err, nil)
+			gobottest.Assert(t, press, float32(100653.26))
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		gobottest.Assert(t, bmp280.Reset(), nil)
+	}
+	wg.Wait()
+	gobottest.Assert(t, bmp280.CalibrationCoefficients(), bmp280TestCalibration)
+}
+
+func TestBMP280DriverConcurrentSetAddress(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	connector := &bmp280TestConnector{i2cTestAdaptor: adaptor}
+	bmp280 := NewBMP280Driver(connector, WithBMP280PollInterval(time.Millisecond))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	defer bmp280.Halt()
+
+	// the poll goroutine reads while the driver reconnects.
+	for _, address := range []int{0x77, 0x76, 0x77} {
+		gobottest.Assert(t, bmp280.SetAddress(address), nil)
+		time.Sleep(2 * time.Millisecond)
+	}
+	gobottest.Assert(t, bmp280.String(), bmp280.Name()+" (BMP280, bus 0, address 0x77)")
+	gobottest.Assert(t, bmp280.Healthy(), true)
+}
+
+func TestBMP280DriverHaltSleep(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bmp280.Halt(), nil)
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterControl, 0x24})
+
+	adaptor.i2cWriteImpl = func([]byte) (int, error) {
+		return 0, errors.New("write error")
+	}
+	gobottest.Assert(t, bmp280.Halt(), errors.New("write error"))
+}
+
+func TestBMP280DriverRawTemperatureAndPressure(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	temp, press, err := bmp280.RawTemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, int32(519888))
+	gobottest.Assert(t, press, int32(415148))
+}
+
+func TestBMP280DriverForcedMode(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280ForcedMode())
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	// the device is left in sleep mode after initialization.
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-2:], []byte{bmp280RegisterControl, 0x24})
+
+	adaptor.written = []byte{}
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, adaptor.written, []byte{
+		bmp280RegisterControl, 0x25,
+		bmp280RegisterStatus,
+		bmp280RegisterTempData,
+	})
+
+	readImpl := adaptor.i2cReadImpl
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterStatus {
+			b[0] = bmp280StatusMeasuring
+			return 1, nil
+		}
+		return readImpl(b)
+	}
+	bmp280.measureTimeout = 5 * time.Millisecond
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, err, errors.New("BMP280: measurement timed out"))
+}
+
+func TestBMP280DriverTrace(t *testing.T) {
+	type access struct {
+		addr byte
+		dir  string
+		data []byte
+	}
+	var accesses []access
+	var errs []error
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280ReadRetries(1, time.Millisecond),
+		WithBMP280Trace(func(addr byte, dir string, data []byte, err error) {
+			accesses = append(accesses, access{addr, dir, data})
+			errs = append(errs, err)
+		}))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, len(accesses), 6)
+	gobottest.Assert(t, accesses[0], access{bmp280RegisterChipID, "read", []byte{bmp280ChipID}})
+	gobottest.Assert(t, accesses[1], access{bmp280RegisterVersion, "read", []byte{0x01}})
+	gobottest.Assert(t, accesses[2].addr, byte(bmp280RegisterCalib00))
+	gobottest.Assert(t, len(accesses[2].data), 24)
+	gobottest.Assert(t, accesses[5], access{bmp280RegisterControl, "write", []byte{0x27}})
+
+	accesses = nil
+	_, err := bmp280.Pressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, accesses, []access{
+		{bmp280RegisterPressureData, "read", []byte{0x65, 0x5a, 0xc0, 0x7e, 0xed, 0x00}},
+	})
+
+	// failed and retried attempts are traced as well.
+	accesses, errs = nil, nil
+	adaptor.i2cReadImpl = func([]byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	_, err = bmp280.Pressure()
+	gobottest.Refute(t, err, nil)
+	gobottest.Assert(t, accesses, []access{
+		{bmp280RegisterPressureData, "read", nil},
+		{bmp280RegisterPressureData, "read", nil},
+	})
+	gobottest.Assert(t, errs, []error{errors.New("read error"), errors.New("read error")})
+
+	accesses, errs = nil, nil
+	adaptor.i2cWriteImpl = func([]byte) (int, error) {
+		return 0, errors.New("write error")
+	}
+	gobottest.Refute(t, bmp280.SetPowerMode(BMP280PowerModeSleep), nil)
+	gobottest.Assert(t, accesses, []access{{bmp280RegisterControl, "write", []byte{0x24}}})
+	gobottest.Assert(t, errs, []error{errors.New("write error")})
+}
+
+func TestBMP280DriverRead(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	adaptor.written = []byte{}
+	m, err := bmp280.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, m.Temperature, float32(25.082478))
+	gobottest.Assert(t, m.Pressure, float32(100653.26))
+	gobottest.Assert(t, m.Humidity, float32(0))
+	gobottest.Assert(t, m.Altitude, float32(56.07641))
+	gobottest.Refute(t, m.Time.IsZero(), true)
+	// a single burst read of the data registers.
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterPressureData})
+
+	adaptor.i2cReadImpl = func([]byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	_, err = bmp280.Read()
+	gobottest.Assert(t, err, errors.New("read error"))
+}
+
+func TestBMP280DriverGetChipID(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	_, err := bmp280.GetChipID()
+	gobottest.Assert(t, err, errors.New("BMP280: driver not started"))
+
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	id, err := bmp280.GetChipID()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, id, byte(0x58))
+}
+
+func TestBMP280DriverWithContext(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+
+	temp, err := bmp280.TemperatureWithContext(context.Background())
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	press, err := bmp280.PressureWithContext(context.Background())
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, float32(100653.26))
+	alt, err := bmp280.AltitudeWithContext(context.Background())
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, alt, float32(56.07641))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = bmp280.TemperatureWithContext(ctx)
+	gobottest.Assert(t, err, context.Canceled)
+
+	// a hung read is abandoned when the deadline expires.
+	release := make(chan struct{})
+	readImpl := adaptor.i2cReadImpl
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		<-release
+		return readImpl(b)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = bmp280.PressureWithContext(ctx)
+	gobottest.Assert(t, err, context.DeadlineExceeded)
+	close(release)
+}
+
+func TestBMP280DriverPressureOutOfRange(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = readImpl
+	bmp280.Start()
+
+	var data []byte
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterPressureData {
+			return copy(b, data), nil
+		}
+		return readImpl(b)
+	}
+	// raw pressures of 0 and 0xfffff compensate to about 1732 hPa and -66 hPa.
+	for _, raw := range [][]byte{
+		{0x00, 0x00, 0x00, 0x7e, 0xed, 0x00},
+		{0xff, 0xff, 0xf0, 0x7e, 0xed, 0x00},
+	} {
+		data = raw
+		_, err := bmp280.Pressure()
+		gobottest.Refute(t, err, nil)
+		_, err = bmp280.Altitude()
+		gobottest.Refute(t, err, nil)
+		_, err = bmp280.Read()
+		gobottest.Refute(t, err, nil)
+	}
+}
+
+func TestBMP280DriverString(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	bmp280.SetName("outdoor")
+	gobottest.Assert(t, bmp280.String(), "outdoor (unknown, bus 0, address 0x76)")
+
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	gobottest.Assert(t, bmp280.String(), "outdoor (BMP280, bus 0, address 0x76)")
+
+	bmp280 = NewBMP280Driver(adaptor, WithBus(2), WithAddress(0x77))
+	bmp280.SetName("indoor")
+	gobottest.Assert(t, bmp280.String(), "indoor (unknown, bus 2, address 0x77)")
+}
+
+func TestBMP280DriverSeaLevelPressureFromAltitude(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	_, err := bmp280.SeaLevelPressureFromAltitude(100)
+	gobottest.Assert(t, err, errors.New("BMP280: driver not started"))
+
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	press, err := bmp280.SeaLevelPressureFromAltitude(56.07641)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, math.Abs(float64(press)-101325) < 0.1, true)
+
+	press, err = bmp280.SeaLevelPressureFromAltitude(250)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, bmp280.seaLevelPressure, press)
+	alt, err := bmp280.Altitude()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, math.Abs(float64(alt)-250) < 0.01, true)
+}
+
+func TestBMP280DriverState(t *testing.T) {
+	bmp280 := NewBMP280Driver(newI2cTestAdaptor(),
+		WithBMP280SeaLevelPressure(102000),
+		WithBMP280StationAltitude(250),
+		WithBMP280TemperatureUnit(TemperatureUnitFahrenheit),
+		WithBMP280PressureUnit(PressureUnitHectopascal),
+		WithBMP280TemperatureOversampling(BMP280Oversampling2x),
+		WithBMP280PressureOversampling(BMP280Oversampling16x),
+		WithBMP280IIRFilter(BMP280Filter4),
+		WithBMP280StandbyTime(BMP280Standby125ms))
+	bmp280.SetSeaLevelPressure(101800)
+	state := bmp280.State()
+	gobottest.Assert(t, state, BMP280State{
+		SeaLevelPressure:        101800,
+		StationAltitude:         250,
+		TemperatureUnit:         TemperatureUnitFahrenheit,
+		PressureUnit:            PressureUnitHectopascal,
+		TemperatureOversampling: BMP280Oversampling2x,
+		PressureOversampling:    BMP280Oversampling16x,
+		Filter:                  BMP280Filter4,
+		StandbyTime:             BMP280Standby125ms,
+	})
+
+	data, err := json.Marshal(state)
+	gobottest.Assert(t, err, nil)
+	var decoded BMP280State
+	gobottest.Assert(t, json.Unmarshal(data, &decoded), nil)
+	restored := NewBMP280Driver(newI2cTestAdaptor(), WithBMP280State(decoded))
+	gobottest.Assert(t, restored.State(), state)
+	gobottest.Assert(t, restored.seaLevelPressure, float32(101800))
+	gobottest.Assert(t, restored.pressOversampling, BMP280Oversampling16x)
+}
+
+func TestBMP280DriverTemperatureOnlyRead(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	var requested []int
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		requested = append(requested, len(b))
+		return readImpl(b)
+	}
+	bmp280.Start()
+	adaptor.written = []byte{}
+	requested = nil
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterTempData})
+	gobottest.Assert(t, requested, []int{3})
+
+	// the pressure still uses the combined read.
+	adaptor.written = []byte{}
+	requested = nil
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterPressureData})
+	gobottest.Assert(t, requested, []int{6})
+}
+
+func TestBMP280DriverMeasurementDuration(t *testing.T) {
+	var tests = []struct {
+		temp, press BMP280Oversampling
+		duration    time.Duration
+	}{
+		{BMP280Oversampling1x, BMP280Oversampling1x, 6425 * time.Microsecond},
+		{BMP280Oversampling1x, BMP280OversamplingSkip, 3550 * time.Microsecond},
+		{BMP280Oversampling2x, BMP280Oversampling16x, 43225 * time.Microsecond},
+		{BMP280OversamplingSkip, BMP280OversamplingSkip, 1250 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		bmp280 := NewBMP280Driver(newI2cTestAdaptor(),
+			WithBMP280TemperatureOversampling(tt.temp),
+			WithBMP280PressureOversampling(tt.press))
+		gobottest.Assert(t, bmp280.MeasurementDuration(), tt.duration)
+	}
+}
+
+func TestBMP280DriverRelativePressure(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor)
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	// at sea level, the relative pressure is the station pressure.
+	press, err := bmp280.RelativePressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, float32(100653.26))
+
+	bmp280 = NewBMP280Driver(adaptor, WithBMP280StationAltitude(100),
+		WithBMP280PressureUnit(PressureUnitHectopascal))
+	bmp280.Start()
+	press, err = bmp280.RelativePressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, float32(1018.1187))
+}
+
+func TestBMP280ParseCalibration(t *testing.T) {
+	_, err := bmp280ParseCalibration(make([]byte, 20))
+	gobottest.Assert(t, err.Error(), "BMP280: invalid calibration data, check wiring, expected 24 bytes, got 20")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidCalibration), true)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, bmp280TestCalibration)
+	c, err := bmp280ParseCalibration(buf.Bytes())
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, c, bmp280TestCalibration)
+
+	// the reserved register and dig_H1 of the BME280 following the block are refused.
+	_, err = bmp280ParseCalibration(append(buf.Bytes(), 0x00, 0x4b))
+	gobottest.Assert(t, err.Error(), "BMP280: invalid calibration data, check wiring, expected 24 bytes, got 26")
+
+	// the register dump of the datasheet example, 0x88 to 0x9F.
+	c, err = bmp280ParseCalibration([]byte{112, 107, 67, 103, 24, 252, 125, 142, 67, 214, 208, 11,
+		39, 11, 140, 0, 249, 255, 140, 60, 248, 198, 112, 23})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, c, bmp280TestCalibration)
+}
+
+func TestParseBMP280CalibrationBigEndian(t *testing.T) {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, bmp280TestCalibration)
+	c, err := ParseBMP280Calibration(buf.Bytes(), binary.BigEndian)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, c, bmp280TestCalibration)
+
+	// dig_T1 27504, 0x6B70, reads as 0x706B with the bytes swapped.
+	c, err = ParseBMP280Calibration(buf.Bytes(), binary.LittleEndian)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, c.T1, uint16(0x706b))
+}
+
+func TestBMP280DriverCalibrationData(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	_, err := bmp280.CalibrationData()
+	gobottest.Assert(t, err, ErrBMP280NotStarted)
+
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	adaptor.written = []byte{}
+	data, err := bmp280.CalibrationData()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, adaptor.written, []byte{bmp280RegisterCalib00})
+	c, err := ParseBMP280Calibration(data, binary.LittleEndian)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, c, bmp280.CalibrationCoefficients())
+}
+
+func TestBMP280DriverCalibrationParser(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	var parsed []byte
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280CalibrationParser(func(data []byte) (BMP280CalibrationCoefficients, error) {
+		parsed = data
+		c, err := bmp280ParseCalibration(data)
+		c.T3 = 0
+		return c, err
+	}))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, len(parsed), 24)
+	c := bmp280TestCalibration
+	c.T3 = 0
+	gobottest.Assert(t, bmp280.CalibrationCoefficients(), c)
+
+	bmp280 = NewBMP280Driver(adaptor, WithBMP280CalibrationParser(func([]byte) (BMP280CalibrationCoefficients, error) {
+		return BMP280CalibrationCoefficients{}, errors.New("unknown layout")
+	}))
+	gobottest.Assert(t, bmp280.Start(), errors.New("unknown layout"))
+}
+
+func TestBMP280DriverCalibrationReadLength(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	var length int
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280Trace(func(addr byte, dir string, data []byte, _ error) {
+		if addr == bmp280RegisterCalib00 && dir == "read" {
+			length = len(data)
+		}
+	}))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, length, 24)
+}
+
+func TestBMP280DriverPresetCalibration(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280Calibration(bmp280TestCalibration))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, bytes.IndexByte(adaptor.written, bmp280RegisterCalib00), -1)
+	gobottest.Assert(t, bmp280.CalibrationCoefficients(), bmp280TestCalibration)
+
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+}
+
+func TestBMP280DriverRoundTo(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280RoundTo(2))
+	bmp280.Start()
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.08))
+	gobottest.Assert(t, press, float32(100653.26))
+	temp, err = bmp280.TemperatureCelsius()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.08))
+
+	bmp280 = NewBMP280Driver(adaptor, WithBMP280RoundTo(0))
+	bmp280.Start()
+	m, err := bmp280.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, m.Temperature, float32(25))
+	gobottest.Assert(t, m.Pressure, float32(100653))
+	// the altitude is not rounded.
+	gobottest.Assert(t, m.Altitude, float32(56.07641))
+
+	bmp280 = NewBMP280Driver(adaptor, WithBMP280RoundTo(1),
+		WithBMP280PressureUnit(PressureUnitHectopascal))
+	bmp280.Start()
+	press, err = bmp280.Pressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, float32(1006.5))
+}
+
+func TestBMP280DriverMuxChannel(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	connector := &bmp280TestConnector{i2cTestAdaptor: adaptor}
+	bmp280 := NewBMP280Driver(connector, WithBMP280MuxChannel(0x70, 3))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, adaptor.written[:2], []byte{0x08, bmp280RegisterChipID})
+
+	adaptor.written = []byte{}
+	_, err := bmp280.Pressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, adaptor.written, []byte{0x08, bmp280RegisterPressureData})
+
+	adaptor.written = []byte{}
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeSleep), nil)
+	gobottest.Assert(t, adaptor.written, []byte{0x08, bmp280RegisterControl, 0x24})
+
+	// the mux is at an address without device.
+	bmp280 = NewBMP280Driver(connector, WithBMP280MuxChannel(0x10, 3))
+	gobottest.Assert(t, bmp280.Start(), errors.New("no device"))
+
+	bmp280 = NewBMP280Driver(connector, WithBMP280MuxChannel(0x70, 8))
+	gobottest.Assert(t, bmp280.Start(), errors.New("BMP280: invalid mux channel 8"))
+
+	bmp280 = NewBMP280Driver(nil, WithBMP280Connection(adaptor), WithBMP280MuxChannel(0x70, 1))
+	gobottest.Assert(t, bmp280.Start(), errors.New("BMP280: a connector is needed for the mux"))
+}
+
+func TestBMP280DriverSelfTest(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	err := bmp280.SelfTest()
+	gobottest.Assert(t, err.Error(), "BMP280: self test: BMP280: driver not started")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280NotStarted), true)
+
+	readImpl := bmp280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = readImpl
+	bmp280.Start()
+	gobottest.Assert(t, bmp280.SelfTest(), nil)
+
+	id := byte(0x55)
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterChipID {
+			b[0] = id
+			return 1, nil
+		}
+		return readImpl(b)
+	}
+	err = bmp280.SelfTest()
+	gobottest.Assert(t, err.Error(), "BMP280: self test: BMP280: unexpected chip id 0x55")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280BadChipID), true)
+
+	id = bmp280ChipID
+	*bmp280.tpc = BMP280CalibrationCoefficients{}
+	err = bmp280.SelfTest()
+	gobottest.Assert(t, err.Error(), "BMP280: self test: BMP280: invalid calibration data, check wiring, all coefficients are zero")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidCalibration), true)
+
+	// raw temperature 720000 and pressure 500000.
+	*bmp280.tpc = bmp280TestCalibration
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterPressureData {
+			return copy(b, []byte{0x7a, 0x12, 0x00, 0xaf, 0xc8, 0x00}), nil
+		}
+		return readImpl(b)
+	}
+	err = bmp280.SelfTest()
+	gobottest.Assert(t, err.Error(), "BMP280: self test: BMP280: measurement out of the valid range, temperature 87.33")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280OutOfRange), true)
+}
+
+func TestBMP280DriverSelfTestUnfiltered(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	var observed []string
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280MinReadInterval(time.Hour), WithBMP280MovingAverage(4),
+		WithBMP280MetricSink(func(name string, value float64) { observed = append(observed, name) }))
+	readImpl := bmp280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = readImpl
+	gobottest.Assert(t, bmp280.Start(), nil)
+	_, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	observed = nil
+
+	// raw temperature 720000, out of range, neither cached nor averaged.
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterPressureData {
+			return copy(b, []byte{0x7a, 0x12, 0x00, 0xaf, 0xc8, 0x00}), nil
+		}
+		return readImpl(b)
+	}
+	err = bmp280.SelfTest()
+	gobottest.Assert(t, err.Error(), "BMP280: self test: BMP280: measurement out of the valid range, temperature 87.33")
+	gobottest.Assert(t, len(observed), 0)
+}
+
+func TestBMP280MeasurementJSON(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280TemperatureUnit(TemperatureUnitFahrenheit),
+		WithBMP280PressureUnit(PressureUnitHectopascal))
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	bmp280.Start()
+	m, err := bmp280.Read()
+	gobottest.Assert(t, err, nil)
+	m.Time = time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// the units are fixed, regardless of the configured ones.
+	data, err := json.Marshal(m)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, string(data),
+		`{"temperature_c":25.082478,"pressure_pa":100653.26,"altitude_m":56.07641,"timestamp":"2017-05-01T12:00:00Z"}`)
+
+	var decoded BMP280Measurement
+	gobottest.Assert(t, json.Unmarshal(data, &decoded), nil)
+	gobottest.Assert(t, decoded.Temperature, float32(25.082478))
+	gobottest.Assert(t, decoded.Pressure, float32(100653.26))
+	gobottest.Assert(t, decoded.Altitude, m.Altitude)
+	gobottest.Assert(t, decoded.Time.Equal(m.Time), true)
+	again, err := json.Marshal(decoded)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, string(again), string(data))
+
+	gobottest.Refute(t, json.Unmarshal([]byte(`{"temperature_c":"warm"}`), &decoded), nil)
+
+	// a measurement built by the caller is in celsius degrees and pascals.
+	data, err = json.Marshal(BMP280Measurement{Temperature: 25, Pressure: 100000})
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, string(data),
+		`{"temperature_c":25,"pressure_pa":100000,"altitude_m":0,"timestamp":"0001-01-01T00:00:00Z"}`)
+}
+
+// bmp280TestRegistersReadImpl returns a read implementation keeping the
+// values written to the ctrl_meas and config registers, unless ignoreWrites is set.
+func bmp280TestRegistersReadImpl(adaptor *i2cTestAdaptor, ignoreWrites *bool) func([]byte) (int, error) {
+	readImpl := bmp280TestReadImpl(adaptor)
+	regs := map[byte]byte{}
+	seen := 0
+	return func(b []byte) (int, error) {
+		// replay the register writes since the last read.
+		w := adaptor.written
+		for i := seen; i+1 < len(w); i++ {
+			if (w[i] == bmp280RegisterControl || w[i] == bmp280RegisterConfig) && !*ignoreWrites {
+				regs[w[i]] = w[i+1]
+				i++
+			}
+		}
+		seen = len(w)
+		switch w[len(w)-1] {
+		case bmp280RegisterControl:
+			b[0] = regs[bmp280RegisterControl]
+			if len(b) > 1 {
+				b[1] = regs[bmp280RegisterConfig]
+			}
+			return len(b), nil
+		case bmp280RegisterConfig:
+			b[0] = regs[bmp280RegisterConfig]
+			return 1, nil
+		}
+		return readImpl(b)
+	}
+}
+
+func TestBMP280DriverSettings(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	ignoreWrites := false
+	adaptor.i2cReadImpl = bmp280TestRegistersReadImpl(adaptor, &ignoreWrites)
+	bmp280 := NewBMP280Driver(adaptor,
+		WithBMP280TemperatureOversampling(BMP280Oversampling2x),
+		WithBMP280PressureOversampling(BMP280Oversampling16x),
+		WithBMP280IIRFilter(BMP280Filter4),
+		WithBMP280StandbyTime(BMP280Standby1000ms),
+		WithBMP280WriteVerification())
+	gobottest.Assert(t, bmp280.Start(), nil)
+	settings, err := bmp280.Settings()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, settings, BMP280Settings{
+		PowerMode:               BMP280PowerModeNormal,
+		TemperatureOversampling: BMP280Oversampling2x,
+		PressureOversampling:    BMP280Oversampling16x,
+		Filter:                  BMP280Filter4,
+		StandbyTime:             BMP280Standby1000ms,
+	})
+
+	// the mode bits are not verified in forced mode, as the device returns to sleep.
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeForced), nil)
+
+	ignoreWrites = true
+	err = bmp280.SetPowerMode(BMP280PowerModeSleep)
+	gobottest.Assert(t, err.Error(), "BMP280: register write did not take effect, register 0xF4 reads 0x55 instead of 0x54")
+	gobottest.Assert(t, errors.Is(err, ErrBMP280WriteVerification), true)
+}
+
+func TestBMP280DriverMovingAverage(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = readImpl
+	WithBMP280MovingAverage(2)(bmp280)
+	bmp280.Start()
+
+	temp, err := bmp280.TemperatureCelsius()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+
+	// raw temperature 600000.
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		if adaptor.written[len(adaptor.written)-1] == bmp280RegisterTempData {
+			return copy(b, []byte{0x92, 0x7c, 0x00}), nil
+		}
+		return readImpl(b)
+	}
+	temp, err = bmp280.TemperatureCelsius()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32((25.08247793081682+50.109787283465266)/2))
+	temp, err = bmp280.TemperatureCelsius()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(50.109787283465266))
+
+	press, err := bmp280.Pressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, press, float32(100653.26))
+}
+
+func TestBMP280MovingAverage(t *testing.T) {
+	gobottest.Assert(t, newBMP280MovingAverage(1) == nil, true)
+	var none *bmp280MovingAverage
+	gobottest.Assert(t, none.add(3), 3.0)
+
+	a := newBMP280MovingAverage(3)
+	for i, want := range []float64{1, 1.5, 2, 3, 4} {
+		gobottest.Assert(t, a.add(float64(i+1)), want)
+	}
+}
+
+func TestBMP280DriverOffsets(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	WithBMP280TemperatureOffset(-0.5)(bmp280)
+	WithBMP280PressureOffset(12, 1.001)(bmp280)
+	bmp280.Start()
+
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478-0.5))
+	gobottest.Assert(t, press, float32(100653.26*1.001+12))
+	m, err := bmp280.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, m.Temperature, temp)
+	gobottest.Assert(t, m.Pressure, press)
+
+	// the fine temperature and the fixed-point values are not corrected.
+	fixedT, fixedP, err := bmp280.FixedTemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, fixedT, int32(2508))
+	gobottest.Assert(t, fixedP, uint32(25767233))
+
+	WithBMP280TemperatureOffset(0, 2)(bmp280)
+	temp, err = bmp280.TemperatureCelsius()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478*2))
+}
+
+func TestBMP280DriverKalmanFilter(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	WithBMP280KalmanFilter(1, 4)(bmp280)
+	bmp280.Start()
+
+	// a constant pressure is left as it is.
+	for i := 0; i < 3; i++ {
+		press, err := bmp280.Pressure()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, press, float32(100653.26))
+	}
+	gobottest.Assert(t, bmp280.pressKalman.started, true)
+	gobottest.Assert(t, bmp280.Clone().pressKalman.started, false)
+}
+
+func TestBMP280KalmanFilter(t *testing.T) {
+	gobottest.Assert(t, newBMP280KalmanFilter(0, 4) == nil, true)
+	gobottest.Assert(t, newBMP280KalmanFilter(1, 0) == nil, true)
+	var none *bmp280KalmanFilter
+	gobottest.Assert(t, none.add(3), 3.0)
+
+	f := newBMP280KalmanFilter(1, 4)
+	gobottest.Assert(t, f.add(100), 100.0)
+	// covariance 5, gain 5/9, then covariance 20/9.
+	gobottest.Assert(t, math.Abs(f.add(110)-(100+50.0/9)) < 1e-9, true)
+	gobottest.Assert(t, math.Abs(f.covariance-20.0/9) < 1e-9, true)
+}
+
+func TestBMP280DriverNegativeTemperatures(t *testing.T) {
+	bmp280, adaptor := initTestBMP280DriverWithStubbedAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	adaptor.i2cReadImpl = readImpl
+	bmp280.Start()
+
+	// the 20 bit readings are unsigned, only the compensated value gets negative.
+	var tests = []struct {
+		raw   int32
+		temp  float64
+		tFine int32
+	}{
+		{raw: 400000, temp: -12.64360672980547, tFine: -64735},
+		{raw: 380000, temp: -18.968968339730054, tFine: -97121},
+		{raw: 350000, temp: -28.474063780275173, tFine: -145787},
+		// a reading with the most significant bit set is not sign extended,
+		// while a msb of 0xFF is refused as a corrupt frame.
+		{raw: 0xeff00, temp: 167.67863273620605, tFine: 858514},
+	}
+	for _, tt := range tests {
+		data := []byte{byte(tt.raw >> 12), byte(tt.raw >> 4), byte(tt.raw << 4)}
+		adaptor.i2cReadImpl = func(b []byte) (int, error) {
+			if adaptor.written[len(adaptor.written)-1] == bmp280RegisterTempData {
+				return copy(b, data), nil
+			}
+			return readImpl(b)
+		}
+		raw, err := bmp280.rawTemp()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, raw, tt.raw)
+		temp, tFine := bmp280.calculateTemp(raw)
+		gobottest.Assert(t, temp, tt.temp)
+		gobottest.Assert(t, tFine, tt.tFine)
+		celsius, err := bmp280.TemperatureCelsius()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, celsius, float32(tt.temp))
+	}
+}
+
+func TestBMP280DriverMinReadInterval(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	bmp280 := NewBMP280Driver(adaptor, WithBMP280MinReadInterval(time.Hour))
+	readImpl := bmp280TestReadImpl(adaptor)
+	reads := 0
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		reads++
+		return readImpl(b)
+	}
+	bmp280.Start()
+
+	reads = 0
+	for i := 0; i < 3; i++ {
+		temp, err := bmp280.Temperature()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, temp, float32(25.082478))
+		press, err := bmp280.Pressure()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, press, float32(100653.26))
+	}
+	// one read per kind of measurement.
+	gobottest.Assert(t, reads, 2)
+
+	// an expired reading is read again.
+	bmp280.cache.interval = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	_, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, reads, 3)
+
+	// failed reads are not cached.
+	adaptor.i2cReadImpl = func([]byte) (int, error) {
+		return 0, errors.New("read error")
+	}
+	bmp280.cache.interval = time.Hour
+	bmp280.cache.entries = map[int]bmp280CacheEntry{}
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, err, errors.New("read error"))
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, err, errors.New("read error"))
+
+	bmp280 = NewBMP280Driver(adaptor, WithBMP280MinReadInterval(time.Hour), WithBMP280MinReadInterval(0))
+	gobottest.Assert(t, bmp280.cache == nil, true)
+}
@@ -0,0 +1,252 @@
+package i2c
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"gobot.io/x/gobot/sysfs"
+)
+
+// BMP280Transport is the register access of the BMP280Driver. It is an i2c
+// connection by default, and can be replaced with the WithBMP280Transport
+// option, e.g. by a BMP280RegisterMap to run without the device.
+type BMP280Transport interface {
+	// ReadRegisters reads len(data) bytes starting at the given register.
+	ReadRegisters(reg byte, data []byte) error
+	// WriteRegister writes a single byte to the given register.
+	WriteRegister(reg byte, val byte) error
+}
+
+// bmp280CombinedReader is implemented by connections supporting a combined
+// register read: the register address is written, and the data read after
+// a repeated start, in a single bus transaction. This is the case of the
+// connections of the sysfs based adaptors, if the adapter supports I2C block
+// reads. The ReadBlockData of others, as the firmata one, writes and reads in
+// two transactions, and is not used.
+type bmp280CombinedReader interface {
+	ReadI2cBlockData(reg uint8, b []byte) (n int, err error)
+}
+
+// bmp280MaxBlockRead is the largest SMBus I2C block read. Larger register
+// ranges are written and read in two transactions.
+const bmp280MaxBlockRead = 32
+
+// bmp280ConnectionTransport is the BMP280Transport over an i2c connection.
+// Registers are read in a single transaction if the connection implements
+// ReadI2cBlockData(reg uint8, b []byte) (n int, err error), the adapter
+// supports it, and at most 32 bytes are read. Else the register address is written in a first transaction,
+// ended by a stop, and exactly len(data) bytes read in a second one. The
+// BMP280 keeps its register pointer across the stop, but another master on
+// the bus may move it, and some adaptors reset it.
+// With a read delay, the two transactions are always used, separated by it.
+// The errors of the connection are reported as a BMP280BusError when they
+// carry the errno of the i2c bus device.
+type bmp280ConnectionTransport struct {
+	connection Connection
+	address    int
+	readDelay  time.Duration
+}
+
+func (t bmp280ConnectionTransport) ReadRegisters(reg byte, data []byte) error {
+	// a combined transaction prevents another master from addressing the
+	// device between writing the register address and reading the data.
+	if cr, ok := t.connection.(bmp280CombinedReader); ok && t.readDelay <= 0 && len(data) <= bmp280MaxBlockRead {
+		bytesRead, err := cr.ReadI2cBlockData(reg, data)
+		if !errors.Is(err, sysfs.ErrI2cBlockReadNotSupported) {
+			if err != nil {
+				return bmp280BusFault(t.address, err)
+			}
+			if bytesRead < len(data) {
+				return fmt.Errorf("%w, expected %d bytes, read %d", ErrBMP280ShortRead, len(data), bytesRead)
+			}
+			return nil
+		}
+	}
+	if _, err := t.connection.Write([]byte{reg}); err != nil {
+		return bmp280BusFault(t.address, err)
+	}
+	if t.readDelay > 0 {
+		time.Sleep(t.readDelay)
+	}
+	bytesRead, err := t.connection.Read(data)
+	if err != nil {
+		return bmp280BusFault(t.address, err)
+	}
+	if bytesRead != len(data) {
+		return fmt.Errorf("%w, expected %d bytes, read %d", ErrBMP280ShortRead, len(data), bytesRead)
+	}
+	return nil
+}
+
+func (t bmp280ConnectionTransport) WriteRegister(reg byte, val byte) error {
+	return bmp280BusFault(t.address, t.connection.WriteByteData(reg, val))
+}
+
+// BMP280BusError is a failed i2c transaction with the device, telling a
+// device that did not acknowledge its address, e.g. absent or at another
+// address, from a failure of the bus itself, e.g. wiring or the adaptor.
+// It wraps the error of the connection, and is to be checked with errors.As.
+type BMP280BusError struct {
+	Address  int
+	NoDevice bool
+	Err      error
+}
+
+func (e *BMP280BusError) Error() string {
+	if e.NoDevice {
+		return fmt.Sprintf("BMP280: no device at 0x%02x: %v", e.Address, e.Err)
+	}
+	return fmt.Sprintf("BMP280: i2c bus error at 0x%02x: %v", e.Address, e.Err)
+}
+
+func (e *BMP280BusError) Unwrap() error {
+	return e.Err
+}
+
+// bmp280NackErrnos are the errnos of the i2c bus device for an address
+// without acknowledge. Others are bus errors, e.g. EIO or ETIMEDOUT.
+var bmp280NackErrnos = []syscall.Errno{syscall.ENXIO}
+
+// bmp280BusFault wraps the error of a connection in a BMP280BusError, if it
+// carries an errno to tell a missing device from a bus error.
+func bmp280BusFault(address int, err error) error {
+	var errno syscall.Errno
+	if err == nil || !errors.As(err, &errno) {
+		return err
+	}
+	busErr := &BMP280BusError{Address: address, Err: err}
+	for _, nack := range bmp280NackErrnos {
+		if errno == nack {
+			busErr.NoDevice = true
+		}
+	}
+	return busErr
+}
+
+// BMP280SPIConnection is a full duplex SPI connection to a BMP280, with the
+// chip select asserted for the duration of each transfer, e.g. the Tx
+// method of an SPI adaptor.
+type BMP280SPIConnection interface {
+	// Tx writes w and reads r in the same transfer, len(r) equals len(w).
+	Tx(w []byte, r []byte) error
+}
+
+// bmp280SPITransport is the BMP280Transport over 4 wire SPI.
+type bmp280SPITransport struct {
+	connection BMP280SPIConnection
+}
+
+// NewBMP280SPITransport returns the BMP280Transport over an SPI connection,
+// in mode 0 or 3, for the WithBMP280Transport option. The compensation is the
+// same as over i2c. Over SPI, the most significant bit of the register address
+// selects a read, and is dropped for a write.
+func NewBMP280SPITransport(connection BMP280SPIConnection) BMP280Transport {
+	return bmp280SPITransport{connection: connection}
+}
+
+func (t bmp280SPITransport) ReadRegisters(reg byte, data []byte) error {
+	// the address byte is followed by the auto-incremented registers.
+	w := make([]byte, len(data)+1)
+	r := make([]byte, len(data)+1)
+	w[0] = reg | 0x80
+	if err := t.connection.Tx(w, r); err != nil {
+		return err
+	}
+	copy(data, r[1:])
+	return nil
+}
+
+func (t bmp280SPITransport) WriteRegister(reg byte, val byte) error {
+	return t.connection.Tx([]byte{reg &^ 0x80, val}, make([]byte, 2))
+}
+
+// BMP280RegisterMap is an in-memory BMP280Transport, e.g. to run the driver
+// against a recorded register dump in tests and demos. Registers never
+// written read as 0x00. It is safe for concurrent use.
+type BMP280RegisterMap struct {
+	mutex     sync.Mutex
+	registers map[byte]byte
+}
+
+// NewBMP280RegisterMap creates a register map with the given initial values.
+func NewBMP280RegisterMap(registers map[byte]byte) *BMP280RegisterMap {
+	m := &BMP280RegisterMap{registers: make(map[byte]byte, len(registers))}
+	for reg, val := range registers {
+		m.registers[reg] = val
+	}
+	return m
+}
+
+// LoadBMP280RegisterMap creates a register map from a JSON fixture. Its keys
+// are register addresses, in decimal or 0x prefixed hexadecimal, and its
+// values are either a byte or an array of bytes of consecutive registers:
+//
+//		{"0xd0": 88, "0x88": [112, 107, 67, 103, 24, 252]}
+func LoadBMP280RegisterMap(r io.Reader) (*BMP280RegisterMap, error) {
+	var fixture map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&fixture); err != nil {
+		return nil, err
+	}
+	m := NewBMP280RegisterMap(nil)
+	for key, raw := range fixture {
+		reg, err := strconv.ParseUint(key, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("BMP280: invalid register %q", key)
+		}
+		var values []int
+		if json.Unmarshal(raw, &values) != nil {
+			var val int
+			if err := json.Unmarshal(raw, &val); err != nil {
+				return nil, fmt.Errorf("BMP280: invalid value of register %q", key)
+			}
+			values = []int{val}
+		}
+		if int(reg)+len(values) > 0x100 {
+			return nil, fmt.Errorf("BMP280: values of register %q beyond 0xFF", key)
+		}
+		for i, val := range values {
+			if val < 0 || val > 0xff {
+				return nil, fmt.Errorf("BMP280: invalid value %d of register 0x%02X", val, int(reg)+i)
+			}
+			m.registers[byte(int(reg)+i)] = byte(val)
+		}
+	}
+	return m, nil
+}
+
+// ReadRegisters reads len(data) bytes starting at the given register.
+func (m *BMP280RegisterMap) ReadRegisters(reg byte, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if int(reg)+len(data) > 0x100 {
+		return fmt.Errorf("%w, expected %d bytes, read %d", ErrBMP280ShortRead, len(data), 0x100-int(reg))
+	}
+	for i := range data {
+		data[i] = m.registers[reg+byte(i)]
+	}
+	return nil
+}
+
+// WriteRegister writes a single byte to the given register.
+func (m *BMP280RegisterMap) WriteRegister(reg byte, val byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.registers[reg] = val
+	return nil
+}
+
+// Register returns the current value of the given register.
+func (m *BMP280RegisterMap) Register(reg byte) byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.registers[reg]
+}
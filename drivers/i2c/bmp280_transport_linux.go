@@ -0,0 +1,9 @@
+package i2c
+
+import "syscall"
+
+func init() {
+	// some adapters, e.g. the one of the Raspberry Pi, report an address
+	// without acknowledge as a remote I/O error.
+	bmp280NackErrnos = append(bmp280NackErrnos, syscall.EREMOTEIO)
+}
@@ -0,0 +1,394 @@
+package i2c
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+// bmp280TestFixture is the register dump of the datasheet compensation example.
+const bmp280TestFixture = `{
+	"0xd0": 88,
+	"0x88": [112, 107, 67, 103, 24, 252, 125, 142, 67, 214, 208, 11,
+		39, 11, 140, 0, 249, 255, 140, 60, 248, 198, 112, 23],
+	"0xf7": [101, 90, 192, 126, 237, 0]
+}`
+
+func TestBMP280DriverTransport(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs),
+		WithBMP280TemperatureOversampling(BMP280Oversampling2x))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, *bmp280.tpc, bmp280TestCalibration)
+	gobottest.Assert(t, regs.Register(bmp280RegisterControl), uint8(0x47))
+
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+}
+
+func TestBMP280DriverTransportNotStarted(t *testing.T) {
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(NewBMP280RegisterMap(nil)))
+	_, err := bmp280.Temperature()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280NotStarted), true)
+}
+
+func TestBMP280DriverTransportPanic(t *testing.T) {
+	defer func() {
+		gobottest.Assert(t, recover(), "Trying to set transport for non-BMP280Driver")
+	}()
+	NewBMP180Driver(newI2cTestAdaptor(), WithBMP280Transport(NewBMP280RegisterMap(nil)))
+}
+
+func TestBMP280RegisterMap(t *testing.T) {
+	regs := NewBMP280RegisterMap(map[byte]byte{0xfe: 1, 0xff: 2})
+	data := make([]byte, 2)
+	gobottest.Assert(t, regs.ReadRegisters(0xfe, data), nil)
+	gobottest.Assert(t, data, []byte{1, 2})
+	gobottest.Assert(t, regs.WriteRegister(0x10, 3), nil)
+	gobottest.Assert(t, regs.Register(0x10), uint8(3))
+	gobottest.Assert(t, regs.Register(0x11), uint8(0))
+
+	err := regs.ReadRegisters(0xff, data)
+	gobottest.Assert(t, errors.Is(err, ErrBMP280ShortRead), true)
+}
+
+func TestLoadBMP280RegisterMapError(t *testing.T) {
+	for fixture, msg := range map[string]string{
+		`{"0x100": 1}`:        `BMP280: invalid register "0x100"`,
+		`{"0xf0": "a"}`:       `BMP280: invalid value of register "0xf0"`,
+		`{"0xfe": [1, 2, 3]}`: `BMP280: values of register "0xfe" beyond 0xFF`,
+		`{"16": [1, 256]}`:    `BMP280: invalid value 256 of register 0x11`,
+	} {
+		_, err := LoadBMP280RegisterMap(strings.NewReader(fixture))
+		gobottest.Assert(t, err.Error(), msg)
+	}
+	_, err := LoadBMP280RegisterMap(strings.NewReader(`[1]`))
+	gobottest.Refute(t, err, nil)
+}
+
+func TestBMP280DriverNoMeasurement(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	for i, val := range []byte{0x80, 0x00, 0x00, 0x80, 0x00, 0x00} {
+		regs.WriteRegister(bmp280RegisterPressureData+byte(i), val)
+	}
+
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280NoMeasurement), true)
+	gobottest.Assert(t, err.Error(), "BMP280: no measurement available (no measurement completed yet)")
+	_, _, err = bmp280.RawTemperatureAndPressure()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280NoMeasurement), true)
+	gobottest.Assert(t, bmp280.SetPowerMode(BMP280PowerModeSleep), nil)
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, err.Error(), "BMP280: no measurement available (chip in sleep mode)")
+
+	// a skipped pressure measurement reads as the reset value as well.
+	regs.WriteRegister(bmp280RegisterTempData, 0x7e)
+	regs.WriteRegister(bmp280RegisterTempData+1, 0xed)
+	WithBMP280PressureOversampling(BMP280OversamplingSkip)(bmp280)
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, err.Error(), "BMP280: no measurement available (pressure measurement skipped)")
+}
+
+func TestBMP280DriverForcedModeSettle(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	// the status never reports the end of the measurement, which is not polled.
+	regs.WriteRegister(bmp280RegisterStatus, bmp280StatusMeasuring)
+	var reads []byte
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs), WithBMP280ForcedMode(2*time.Millisecond),
+		WithBMP280Trace(func(addr byte, dir string, data []byte, _ error) {
+			if dir == "read" {
+				reads = append(reads, addr)
+			}
+		}))
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	reads = nil
+	start := time.Now()
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, time.Since(start) >= 2*time.Millisecond, true)
+	gobottest.Assert(t, reads, []byte{bmp280RegisterTempData})
+	gobottest.Assert(t, regs.Register(bmp280RegisterControl)&0x03, uint8(BMP280PowerModeForced))
+
+	// without settle duration, the status is polled until the timeout.
+	WithBMP280ForcedMode()(bmp280)
+	WithBMP280MeasurementTimeout(5 * time.Millisecond)(bmp280)
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280MeasurementTimeout), true)
+}
+
+// bmp280TestShiftedTransport reads the data registers shifted by a byte,
+// as after a clock stretch, the given number of times.
+type bmp280TestShiftedTransport struct {
+	*BMP280RegisterMap
+	shifted *int
+}
+
+func (t bmp280TestShiftedTransport) ReadRegisters(reg byte, data []byte) error {
+	if reg == bmp280RegisterPressureData && *t.shifted > 0 {
+		*t.shifted--
+		data[0] = 0xff
+		return t.BMP280RegisterMap.ReadRegisters(reg, data[1:])
+	}
+	return t.BMP280RegisterMap.ReadRegisters(reg, data)
+}
+
+func TestBMP280DriverCorruptFrame(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	shifted := 1
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(bmp280TestShiftedTransport{regs, &shifted}),
+		WithBMP280MinReadInterval(time.Hour))
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	// a single corrupt frame is read again.
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+	gobottest.Assert(t, shifted, 0)
+
+	shifted = 2
+	WithBMP280MinReadInterval(0)(bmp280)
+	_, _, err = bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280CorruptFrame), true)
+	gobottest.Assert(t, err.Error(), "BMP280: corrupt data frame: FF 65 5A C0 7E ED")
+	gobottest.Assert(t, shifted, 0)
+}
+
+func TestBMP280CorruptFrame(t *testing.T) {
+	gobottest.Assert(t, bmp280CorruptFrame([]byte{0x65, 0x5a, 0xc0, 0x7e, 0xed, 0x00}), false)
+	gobottest.Assert(t, bmp280CorruptFrame([]byte{0x65, 0x5a, 0xc1, 0x7e, 0xed, 0x00}), true)
+	gobottest.Assert(t, bmp280CorruptFrame([]byte{0x65, 0x5a, 0xc0, 0xff, 0xed, 0x00}), true)
+	gobottest.Assert(t, bmp280CorruptFrame([]byte{0x7e, 0xed, 0x08}), true)
+	// the humidity of the BME280 is not checked.
+	gobottest.Assert(t, bmp280CorruptFrame([]byte{0x65, 0x5a, 0xc0, 0x7e, 0xed, 0x00, 0xff, 0xff}), false)
+}
+
+func TestBMP280DriverForcedTemperatureOnly(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	var accesses []byte
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs), WithBMP280ForcedMode(),
+		WithBMP280PressureOversampling(BMP280OversamplingSkip),
+		WithBMP280Trace(func(addr byte, dir string, data []byte, _ error) {
+			accesses = append(accesses, addr)
+		}))
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	accesses = nil
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	// osrs_t 1x, osrs_p skipped and forced mode.
+	gobottest.Assert(t, regs.Register(bmp280RegisterControl), uint8(0x21))
+	gobottest.Assert(t, accesses, []byte{bmp280RegisterControl, bmp280RegisterStatus, bmp280RegisterTempData})
+
+	// the pressure fails without triggering a measurement.
+	accesses = nil
+	_, err = bmp280.Pressure()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280NoMeasurement), true)
+	gobottest.Assert(t, err.Error(), "BMP280: no measurement available (pressure measurement skipped)")
+	_, err = bmp280.Read()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280NoMeasurement), true)
+	gobottest.Assert(t, len(accesses), 0)
+}
+
+// bmp280TestSPIDevice emulates the SPI interface of the BMP280 on a
+// register map, where the registers are addressed without their msb.
+type bmp280TestSPIDevice struct {
+	*BMP280RegisterMap
+	err error
+}
+
+func (s bmp280TestSPIDevice) Tx(w []byte, r []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	if w[0]&0x80 != 0 {
+		return s.ReadRegisters(w[0], r[1:])
+	}
+	for i := 0; i+1 < len(w); i += 2 {
+		s.WriteRegister(w[i]|0x80, w[i+1])
+	}
+	return nil
+}
+
+func TestBMP280DriverSPITransport(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(NewBMP280SPITransport(bmp280TestSPIDevice{BMP280RegisterMap: regs})),
+		WithBMP280TemperatureOversampling(BMP280Oversampling2x))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, *bmp280.tpc, bmp280TestCalibration)
+	gobottest.Assert(t, regs.Register(bmp280RegisterControl), uint8(0x47))
+
+	temp, press, err := bmp280.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+}
+
+func TestBMP280SPITransportError(t *testing.T) {
+	transport := NewBMP280SPITransport(bmp280TestSPIDevice{err: errors.New("spi error")})
+	gobottest.Assert(t, transport.ReadRegisters(bmp280RegisterChipID, make([]byte, 1)), errors.New("spi error"))
+	gobottest.Assert(t, transport.WriteRegister(bmp280RegisterControl, 0x27), errors.New("spi error"))
+}
+
+func TestBMP280DriverDiscardSamples(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	var reads, triggers int
+	trace := WithBMP280Trace(func(addr byte, dir string, data []byte, _ error) {
+		switch {
+		case addr == bmp280RegisterPressureData:
+			reads++
+		case addr == bmp280RegisterControl && dir == "write" && data[0]&0x03 == byte(BMP280PowerModeForced):
+			triggers++
+		}
+	})
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs), WithBMP280DiscardSamples(2), trace)
+	period := time.Duration(float64(time.Second) / bmp280.OutputDataRate())
+	start := time.Now()
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, time.Since(start) >= 2*period, true)
+	gobottest.Assert(t, reads, 2)
+	gobottest.Assert(t, triggers, 0)
+
+	// in forced mode, every discarded sample is measured on demand.
+	reads = 0
+	bmp280 = NewBMP280Driver(nil, WithBMP280Transport(regs), WithBMP280DiscardSamples(3), WithBMP280ForcedMode(), trace)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, reads, 3)
+	gobottest.Assert(t, triggers, 3)
+}
+
+func TestBMP280DriverReadDelay(t *testing.T) {
+	adaptor := newI2cTestAdaptor()
+	readImpl := bmp280TestReadImpl(adaptor)
+	var written time.Time
+	adaptor.i2cWriteImpl = func(b []byte) (int, error) {
+		written = time.Now()
+		return len(b), nil
+	}
+	var gaps []time.Duration
+	adaptor.i2cReadImpl = func(b []byte) (int, error) {
+		gaps = append(gaps, time.Since(written))
+		return readImpl(b)
+	}
+	// the delay splits the combined read of block reading connections as well.
+	conn := &bmp280TestBlockConnection{i2cTestAdaptor: adaptor}
+	bmp280 := NewBMP280Driver(nil, WithBMP280Connection(conn), WithBMP280ReadDelay(2*time.Millisecond))
+	gobottest.Assert(t, bmp280.Start(), nil)
+	gobottest.Assert(t, len(conn.regs), 0)
+	gobottest.Assert(t, len(gaps) > 0, true)
+	for _, gap := range gaps {
+		gobottest.Assert(t, gap >= 2*time.Millisecond, true)
+	}
+}
+
+func TestBMP280DriverReadAllocations(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs))
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		bmp280.TemperatureAndPressure()
+		bmp280.Temperature()
+	})
+	gobottest.Assert(t, allocs, 0.0)
+}
+
+func TestBMP280DriverTrigger(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	var mutex sync.Mutex
+	var triggers int
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs), WithBMP280ForcedMode(),
+		WithBMP280Trace(func(addr byte, dir string, data []byte, _ error) {
+			if addr == bmp280RegisterControl && dir == "write" && data[0]&0x03 == byte(BMP280PowerModeForced) {
+				mutex.Lock()
+				triggers++
+				mutex.Unlock()
+			}
+		}))
+	ready := make(chan interface{}, 1)
+	gobottest.Assert(t, bmp280.On(bmp280.Event(BMP280ReadyEvent), func(data interface{}) {
+		ready <- data
+	}), nil)
+	gobottest.Assert(t, bmp280.Start(), nil)
+
+	gobottest.Assert(t, bmp280.Trigger(), nil)
+	select {
+	case data := <-ready:
+		_, ok := data.(time.Time)
+		gobottest.Assert(t, ok, true)
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("BMP280 Event \"ready\" was not published")
+	}
+	// the triggered measurement is read, and the next read triggers again.
+	temp, err := bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	mutex.Lock()
+	gobottest.Assert(t, triggers, 1)
+	mutex.Unlock()
+	_, err = bmp280.Temperature()
+	gobottest.Assert(t, err, nil)
+	mutex.Lock()
+	gobottest.Assert(t, triggers, 2)
+	mutex.Unlock()
+
+	err = NewBMP280Driver(nil, WithBMP280Transport(regs)).Trigger()
+	gobottest.Assert(t, errors.Is(err, ErrBMP280InvalidSettings), true)
+}
+
+func TestBMP280DriverBusError(t *testing.T) {
+	var tests = map[string]struct {
+		errno    syscall.Errno
+		noDevice bool
+		message  string
+	}{
+		"no device": {errno: syscall.ENXIO, noDevice: true,
+			message: "BMP280: no device at 0x76: write /dev/i2c-1: no such device or address"},
+		"bus error": {errno: syscall.EIO,
+			message: "BMP280: i2c bus error at 0x76: write /dev/i2c-1: input/output error"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			adaptor := newI2cTestAdaptor()
+			adaptor.i2cWriteImpl = func([]byte) (int, error) {
+				return 0, &os.PathError{Op: "write", Path: "/dev/i2c-1", Err: tt.errno}
+			}
+			err := NewBMP280Driver(adaptor).Start()
+			var busErr *BMP280BusError
+			gobottest.Assert(t, errors.As(err, &busErr), true)
+			gobottest.Assert(t, busErr.Address, 0x76)
+			gobottest.Assert(t, busErr.NoDevice, tt.noDevice)
+			gobottest.Assert(t, errors.Is(err, tt.errno), true)
+			gobottest.Assert(t, err.Error(), tt.message)
+		})
+	}
+
+	// errors without errno are left as they are.
+	gobottest.Assert(t, bmp280BusFault(0x76, ErrBMP280ShortRead), ErrBMP280ShortRead)
+	gobottest.Assert(t, bmp280BusFault(0x76, nil), nil)
+}
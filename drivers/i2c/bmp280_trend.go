@@ -0,0 +1,104 @@
+package i2c
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BMP280PressureTrend is the tendency of the barometric pressure, as
+// classified by the PressureTrend method of the BMP280Driver.
+type BMP280PressureTrend int
+
+const (
+	// BMP280PressureSteady is a pressure change within the threshold.
+	BMP280PressureSteady BMP280PressureTrend = iota
+	// BMP280PressureRising is a pressure increase beyond the threshold.
+	BMP280PressureRising
+	// BMP280PressureFalling is a pressure decrease beyond the threshold.
+	BMP280PressureFalling
+)
+
+func (t BMP280PressureTrend) String() string {
+	switch t {
+	case BMP280PressureRising:
+		return "rising"
+	case BMP280PressureFalling:
+		return "falling"
+	default:
+		return "steady"
+	}
+}
+
+// errBMP280NoPressureHistory is returned by the PressureTrend method until
+// two samples at least have been polled.
+var errBMP280NoPressureHistory = errors.New("BMP280: not enough pressure history")
+
+// bmp280PressureHistory keeps the pressures, in pascals, of the last window.
+type bmp280PressureHistory struct {
+	mutex     sync.Mutex
+	window    time.Duration
+	threshold float64
+	samples   []bmp280PressureSample
+}
+
+type bmp280PressureSample struct {
+	time  time.Time
+	press float64
+}
+
+func newBMP280PressureHistory(window time.Duration, threshold float32) *bmp280PressureHistory {
+	return &bmp280PressureHistory{window: window, threshold: float64(threshold)}
+}
+
+// clone returns an empty history with the same window and threshold.
+func (h *bmp280PressureHistory) clone() *bmp280PressureHistory {
+	if h == nil {
+		return nil
+	}
+	return newBMP280PressureHistory(h.window, float32(h.threshold))
+}
+
+// add records the pressure at the given time, and drops the samples older
+// than the window.
+func (h *bmp280PressureHistory) add(press float64, now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	first := 0
+	for first < len(h.samples) && now.Sub(h.samples[first].time) > h.window {
+		first++
+	}
+	h.samples = append(h.samples[first:], bmp280PressureSample{time: now, press: press})
+}
+
+// trend returns the least squares slope of the samples, in pascals per hour,
+// and its classification against the threshold over the window.
+func (h *bmp280PressureHistory) trend() (BMP280PressureTrend, float64, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	n := float64(len(h.samples))
+	if n < 2 || !h.samples[len(h.samples)-1].time.After(h.samples[0].time) {
+		return BMP280PressureSteady, 0, errBMP280NoPressureHistory
+	}
+
+	var sumT, sumP, sumTT, sumTP float64
+	for _, s := range h.samples {
+		t := s.time.Sub(h.samples[0].time).Hours()
+		sumT += t
+		sumP += s.press
+		sumTT += t * t
+		sumTP += t * s.press
+	}
+	rate := (n*sumTP - sumT*sumP) / (n*sumTT - sumT*sumT)
+
+	switch change := rate * h.window.Hours(); {
+	case change > h.threshold:
+		return BMP280PressureRising, rate, nil
+	case change < -h.threshold:
+		return BMP280PressureFalling, rate, nil
+	default:
+		return BMP280PressureSteady, rate, nil
+	}
+}
@@ -0,0 +1,87 @@
+package i2c
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot/gobottest"
+)
+
+func TestBMP280PressureHistory(t *testing.T) {
+	var tests = []struct {
+		hourly float64
+		trend  BMP280PressureTrend
+	}{
+		{hourly: 40, trend: BMP280PressureRising},
+		{hourly: 20, trend: BMP280PressureSteady},
+		{hourly: 0, trend: BMP280PressureSteady},
+		{hourly: -20, trend: BMP280PressureSteady},
+		{hourly: -40, trend: BMP280PressureFalling},
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		h := newBMP280PressureHistory(3*time.Hour, 100)
+		for i := 0; i <= 4*60; i += 10 {
+			h.add(100000+tt.hourly*float64(i)/60, start.Add(time.Duration(i)*time.Minute))
+		}
+		gobottest.Assert(t, len(h.samples), 3*6+1)
+		trend, rate, err := h.trend()
+		gobottest.Assert(t, err, nil)
+		gobottest.Assert(t, trend, tt.trend)
+		gobottest.Assert(t, float32(rate), float32(tt.hourly))
+	}
+}
+
+func TestBMP280PressureHistoryShort(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newBMP280PressureHistory(3*time.Hour, 100)
+	_, _, err := h.trend()
+	gobottest.Assert(t, err, errBMP280NoPressureHistory)
+	h.add(100000, start)
+	_, _, err = h.trend()
+	gobottest.Assert(t, err, errBMP280NoPressureHistory)
+
+	// the rate of the first half hour is extrapolated to the window.
+	h.add(100020, start.Add(30*time.Minute))
+	trend, rate, err := h.trend()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, trend, BMP280PressureRising)
+	gobottest.Assert(t, float32(rate), float32(40))
+}
+
+func TestBMP280PressureTrendString(t *testing.T) {
+	gobottest.Assert(t, BMP280PressureRising.String(), "rising")
+	gobottest.Assert(t, BMP280PressureSteady.String(), "steady")
+	gobottest.Assert(t, BMP280PressureFalling.String(), "falling")
+}
+
+func TestBMP280DriverPressureTrend(t *testing.T) {
+	regs, err := LoadBMP280RegisterMap(strings.NewReader(bmp280TestFixture))
+	gobottest.Assert(t, err, nil)
+	bmp280 := NewBMP280Driver(nil, WithBMP280Transport(regs), WithBMP280PollInterval(time.Millisecond),
+		WithBMP280PressureTrend(3*time.Hour, 100))
+	_, _, err = bmp280.PressureTrend()
+	gobottest.Assert(t, err, errBMP280NoPressureHistory)
+	gobottest.Assert(t, bmp280.Start(), nil)
+	defer bmp280.Halt()
+
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		_, _, err = bmp280.PressureTrend()
+	}
+	trend, rate, err := bmp280.PressureTrend()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, trend, BMP280PressureSteady)
+	gobottest.Assert(t, rate < 1e-3 && rate > -1e-3, true)
+
+	clone := bmp280.Clone()
+	_, _, err = clone.PressureTrend()
+	gobottest.Assert(t, err, errBMP280NoPressureHistory)
+}
+
+func TestBMP280DriverPressureTrendDisabled(t *testing.T) {
+	_, _, err := NewBMP280Driver(nil).PressureTrend()
+	gobottest.Assert(t, err.Error(), "BMP280: pressure trend not enabled, see WithBMP280PressureTrend")
+}
@@ -0,0 +1,195 @@
+package i2c
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gobot.io/x/gobot"
+)
+
+// ErrBMP280NoHumidity is returned when reading the humidity of a device
+// without humidity sensor.
+var ErrBMP280NoHumidity = errors.New("BMP280: humidity is not supported by this device")
+
+// BoschEnvDriver is the gobot driver for either a BMP280 or a BME280, as
+// detected by the chip id on Start. It measures the humidity only on a BME280.
+type BoschEnvDriver struct {
+	*BME280Driver
+
+	hasHumidity bool
+}
+
+// NewBoschEnvDriver creates a new driver with specified i2c interface.
+// Params:
+//		conn Connector - the Adaptor to use with this Driver
+//
+// Optional params:
+//		i2c.WithBus(int):	bus to use with this driver
+//		i2c.WithAddress(int):	address to use with this driver, 0x76 by default or 0x77 with SDO high
+//
+// All of the i2c.WithBMP280... and i2c.WithBME280... options can be used as well.
+//
+func NewBoschEnvDriver(c Connector, options ...func(Config)) *BoschEnvDriver {
+	d := &BoschEnvDriver{
+		BME280Driver: NewBME280Driver(c),
+	}
+	d.SetName(gobot.DefaultName("BoschEnv"))
+	d.duration = d.MeasurementDuration
+	d.initVariant = d.initEnv
+
+	for _, option := range options {
+		option(d)
+	}
+
+	d.addHumidityCommand()
+	return d
+}
+
+func (d *BoschEnvDriver) addHumidityCommand() {
+	d.AddCommand("Humidity", func(params map[string]interface{}) interface{} {
+		hum, err := d.Humidity()
+		return map[string]interface{}{"val": hum, "err": err}
+	})
+}
+
+// Clone returns a new, unstarted driver with the same connector and
+// configuration, to which the given options are then applied.
+func (d *BoschEnvDriver) Clone(options ...func(Config)) *BoschEnvDriver {
+	c := &BoschEnvDriver{BME280Driver: d.BME280Driver.clone()}
+	c.SetName(gobot.DefaultName("BoschEnv"))
+	c.duration = c.MeasurementDuration
+	c.initVariant = c.initEnv
+	c.addHumidityCommand()
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Start initializes the device, and the humidity measurement if it is a BME280.
+func (d *BoschEnvDriver) Start() (err error) {
+	if d.started {
+		return nil
+	}
+	if err = d.start(); err != nil {
+		return err
+	}
+	if err = d.initEnv(); err != nil {
+		return err
+	}
+	if err = d.discard(d.OutputDataRate()); err != nil {
+		return err
+	}
+	d.health.set(nil)
+	d.poll()
+	d.started = true
+	return nil
+}
+
+// Reset performs a soft reset of the device, and then reloads the
+// calibration coefficients and the configuration.
+func (d *BoschEnvDriver) Reset() (err error) {
+	if err = d.BMP280Driver.Reset(); err != nil {
+		return err
+	}
+	return d.initEnv()
+}
+
+// HasHumidity returns whether the device measures the humidity,
+// which is known after Start.
+func (d *BoschEnvDriver) HasHumidity() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.hasHumidity
+}
+
+// MeasurementDuration returns the maximum duration of a measurement with the
+// configured oversampling, including the humidity on a BME280.
+func (d *BoschEnvDriver) MeasurementDuration() time.Duration {
+	if d.HasHumidity() {
+		return d.BME280Driver.MeasurementDuration()
+	}
+	return d.BMP280Driver.MeasurementDuration()
+}
+
+// CompensationModel returns the algorithm used to compensate the readings
+// of the detected device, which is known after Start.
+func (d *BoschEnvDriver) CompensationModel() string {
+	if d.HasHumidity() {
+		return d.BME280Driver.CompensationModel()
+	}
+	return d.BMP280Driver.CompensationModel()
+}
+
+// OutputDataRate returns the number of measurements per second in normal
+// mode with the configured settings of the detected device.
+func (d *BoschEnvDriver) OutputDataRate() float64 {
+	if d.HasHumidity() {
+		return d.BME280Driver.OutputDataRate()
+	}
+	return d.BMP280Driver.OutputDataRate()
+}
+
+// Validate returns ErrBMP280InvalidSettings if the output data rate of the
+// detected device is below the given target rate, in Hz.
+func (d *BoschEnvDriver) Validate(rate float64) error {
+	if d.HasHumidity() {
+		return d.BME280Driver.Validate(rate)
+	}
+	return d.BMP280Driver.Validate(rate)
+}
+
+// Humidity returns the current relative humidity, in percent, or
+// ErrBMP280NoHumidity if the device is a BMP280.
+func (d *BoschEnvDriver) Humidity() (hum float32, err error) {
+	if !d.HasHumidity() {
+		return 0.0, ErrBMP280NoHumidity
+	}
+	return d.BME280Driver.Humidity()
+}
+
+// HumidityWithContext is like Humidity, but returns ctx.Err() as soon
+// as the context is done.
+func (d *BoschEnvDriver) HumidityWithContext(ctx context.Context) (hum float32, err error) {
+	return bmp280WithContext(ctx, d.Humidity)
+}
+
+// DewPoint returns the current dew point, in celsius degrees, or
+// ErrBMP280NoHumidity if the device is a BMP280.
+func (d *BoschEnvDriver) DewPoint() (dew float32, err error) {
+	if !d.HasHumidity() {
+		return 0.0, ErrBMP280NoHumidity
+	}
+	return d.BME280Driver.DewPoint()
+}
+
+// HeatIndex returns the current heat index, in the configured unit, or
+// ErrBMP280NoHumidity if the device is a BMP280.
+func (d *BoschEnvDriver) HeatIndex() (hi float32, err error) {
+	if !d.HasHumidity() {
+		return 0.0, ErrBMP280NoHumidity
+	}
+	return d.BME280Driver.HeatIndex()
+}
+
+// Read returns the values of a single sample, including the humidity
+// on a BME280.
+func (d *BoschEnvDriver) Read() (m BMP280Measurement, err error) {
+	if d.HasHumidity() {
+		return d.BME280Driver.Read()
+	}
+	return d.BMP280Driver.Read()
+}
+
+// initEnv initializes the humidity measurement if the device is a BME280.
+func (d *BoschEnvDriver) initEnv() error {
+	d.mutex.Lock()
+	d.hasHumidity = d.chipID == bme280ChipID
+	hasHumidity := d.hasHumidity
+	d.mutex.Unlock()
+	if !hasHumidity {
+		return nil
+	}
+	return d.initHumidity()
+}
@@ -0,0 +1,162 @@
+package i2c
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/gobottest"
+)
+
+var _ gobot.Driver = (*BoschEnvDriver)(nil)
+
+// --------- HELPERS
+func initTestBoschEnvDriverWithStubbedAdaptor() (*BoschEnvDriver, *i2cTestAdaptor) {
+	adaptor := newI2cTestAdaptor()
+	return NewBoschEnvDriver(adaptor), adaptor
+}
+
+// --------- TESTS
+
+func TestNewBoschEnvDriver(t *testing.T) {
+	// Does it return a pointer to an instance of BoschEnvDriver?
+	var d interface{} = NewBoschEnvDriver(newI2cTestAdaptor())
+	_, ok := d.(*BoschEnvDriver)
+	if !ok {
+		t.Errorf("NewBoschEnvDriver() should have returned a *BoschEnvDriver")
+	}
+}
+
+func TestBoschEnvDriverBMP280(t *testing.T) {
+	d, adaptor := initTestBoschEnvDriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, d.Start(), nil)
+	gobottest.Assert(t, d.Healthy(), true)
+	gobottest.Assert(t, d.HasHumidity(), false)
+	gobottest.Assert(t, d.MeasurementDuration(), 6425*time.Microsecond)
+	gobottest.Assert(t, d.CompensationModel(), "bmp280-float-bosch-rev1.11")
+	gobottest.Assert(t, d.OutputDataRate(), 1/(6925*time.Microsecond).Seconds())
+	gobottest.Assert(t, d.Validate(144), nil)
+	gobottest.Refute(t, d.Validate(145), nil)
+
+	temp, press, err := d.TemperatureAndPressure()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, temp, float32(25.082478))
+	gobottest.Assert(t, press, float32(100653.26))
+	m, err := d.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, m.Humidity, float32(0))
+
+	_, err = d.Humidity()
+	gobottest.Assert(t, err, ErrBMP280NoHumidity)
+	_, err = d.HumidityWithContext(context.Background())
+	gobottest.Assert(t, err, ErrBMP280NoHumidity)
+	_, err = d.DewPoint()
+	gobottest.Assert(t, err, ErrBMP280NoHumidity)
+	_, err = d.HeatIndex()
+	gobottest.Assert(t, err, ErrBMP280NoHumidity)
+	ret := d.Command("Humidity")(map[string]interface{}{}).(map[string]interface{})
+	gobottest.Assert(t, ret["err"], ErrBMP280NoHumidity)
+	gobottest.Assert(t, d.Reset(), nil)
+}
+
+func TestBoschEnvDriverBME280(t *testing.T) {
+	d, adaptor := initTestBoschEnvDriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	gobottest.Assert(t, d.Start(), nil)
+	gobottest.Assert(t, d.HasHumidity(), true)
+	gobottest.Assert(t, d.MeasurementDuration(), 9300*time.Microsecond)
+	gobottest.Assert(t, d.CompensationModel(), "bme280-float-bosch-rev1.1")
+	gobottest.Assert(t, d.OutputDataRate(), 1/(9800*time.Microsecond).Seconds())
+	gobottest.Assert(t, d.Validate(102), nil)
+	gobottest.Refute(t, d.Validate(103), nil)
+
+	hum, err := d.Humidity()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hum, float32(39.275326))
+	m, err := d.Read()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, m.Humidity, float32(39.275326))
+	dew, err := d.DewPoint()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, dew, float32(10.256734))
+	hi, err := d.HeatIndex()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hi, float32(24.671804))
+	ret := d.Command("Humidity")(map[string]interface{}{}).(map[string]interface{})
+	gobottest.Assert(t, ret["val"], float32(39.275326))
+
+	adaptor.written = []byte{}
+	gobottest.Assert(t, d.Start(), nil)
+	gobottest.Assert(t, len(adaptor.written), 0)
+	gobottest.Assert(t, d.Reset(), nil)
+	gobottest.Assert(t, adaptor.written[len(adaptor.written)-4:len(adaptor.written)-2], []byte{bme280RegisterControlHumidity, 0x01})
+}
+
+func TestBoschEnvDriverSetAddress(t *testing.T) {
+	d, adaptor := initTestBoschEnvDriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, d.Start(), nil)
+	gobottest.Assert(t, d.HasHumidity(), false)
+
+	// a BME280 at the new address.
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	gobottest.Assert(t, d.SetAddress(0x77), nil)
+	gobottest.Assert(t, d.HasHumidity(), true)
+	hum, err := d.Humidity()
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hum, float32(39.275326))
+
+	clone := d.Clone()
+	gobottest.Assert(t, clone.Start(), nil)
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, clone.SetAddress(0x76), nil)
+	gobottest.Assert(t, clone.HasHumidity(), false)
+	gobottest.Assert(t, d.HasHumidity(), true)
+}
+
+func TestBoschEnvDriverOptions(t *testing.T) {
+	d := NewBoschEnvDriver(newI2cTestAdaptor(), WithBus(2),
+		WithBME280HumidityOversampling(BMP280Oversampling4x),
+		WithBMP280IIRFilter(BMP280Filter4))
+	gobottest.Assert(t, d.GetBusOrDefault(1), 2)
+	gobottest.Assert(t, d.humOversampling, BMP280Oversampling4x)
+	gobottest.Assert(t, d.filter, BMP280Filter4)
+}
+
+func TestBoschEnvDriverClone(t *testing.T) {
+	d, adaptor := initTestBoschEnvDriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	gobottest.Assert(t, d.Start(), nil)
+
+	clone := d.Clone(WithAddress(0x77))
+	gobottest.Assert(t, clone.Name()[:8], "BoschEnv")
+	gobottest.Assert(t, clone.HasHumidity(), false)
+	gobottest.Assert(t, clone.Start(), nil)
+	gobottest.Assert(t, clone.HasHumidity(), true)
+	gobottest.Assert(t, clone.MeasurementDuration(), 9300*time.Microsecond)
+	ret := clone.Command("Humidity")(map[string]interface{}{}).(map[string]interface{})
+	gobottest.Assert(t, ret["val"], float32(39.275326))
+}
+
+func TestBoschEnvDriverHumidityWithContext(t *testing.T) {
+	// a BMP280 does not measure the humidity.
+	d, adaptor := initTestBoschEnvDriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bmp280TestReadImpl(adaptor)
+	gobottest.Assert(t, d.Start(), nil)
+	_, err := d.HumidityWithContext(context.Background())
+	gobottest.Assert(t, err, ErrBMP280NoHumidity)
+
+	d, adaptor = initTestBoschEnvDriverWithStubbedAdaptor()
+	adaptor.i2cReadImpl = bme280TestReadImpl(adaptor)
+	gobottest.Assert(t, d.Start(), nil)
+	hum, err := d.HumidityWithContext(context.Background())
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, hum, float32(39.275326))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.HumidityWithContext(ctx)
+	gobottest.Assert(t, err, context.Canceled)
+}
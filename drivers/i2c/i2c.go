@@ -45,6 +45,28 @@ type Connector interface {
 // Provided by an Adaptor by implementing the I2cConnector interface.
 type Connection sysfs.I2cOperations
 
+// ScanI2C probes the addresses 0x03 to 0x77 of the given bus, or of the
+// default bus of the Connector if BusNotInitialized, and returns those of
+// the devices acknowledging a single byte read. The devices are not
+// identified, and the connections are left open, as the Connector owns them.
+// An error is only returned if the Connector provides no connection.
+func ScanI2C(c Connector, bus int) ([]byte, error) {
+	if bus == BusNotInitialized {
+		bus = c.GetDefaultBus()
+	}
+	var found []byte
+	for address := 0x03; address <= 0x77; address++ {
+		connection, err := c.GetConnection(address, bus)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = connection.ReadByte(); err == nil {
+			found = append(found, byte(address))
+		}
+	}
+	return found, nil
+}
+
 type i2cConnection struct {
 	bus     sysfs.I2cDevice
 	address int
@@ -127,6 +149,40 @@ func (c *i2cConnection) WriteWordData(reg uint8, val uint16) (err error) {
 	return c.bus.WriteWordData(reg, val)
 }
 
+// ReadBlockData reads a block of bytes, up to 32, starting at a register of
+// the i2c device. If the bus supports it, as the sysfs one, the register is
+// written and the data read in a single transaction, else in two.
+func (c *i2cConnection) ReadBlockData(reg uint8, b []byte) (n int, err error) {
+	if err := c.bus.SetAddress(c.address); err != nil {
+		return 0, err
+	}
+	if br, ok := c.bus.(interface {
+		ReadBlockData(reg uint8, b []byte) (int, error)
+	}); ok {
+		return br.ReadBlockData(reg, b)
+	}
+	if _, err = c.bus.Write([]byte{reg}); err != nil {
+		return 0, err
+	}
+	return c.bus.Read(b)
+}
+
+// ReadI2cBlockData reads a block of bytes, up to 32, starting at a register
+// of the i2c device, in a single combined transaction. It returns
+// sysfs.ErrI2cBlockReadNotSupported if the bus does not support it.
+func (c *i2cConnection) ReadI2cBlockData(reg uint8, b []byte) (n int, err error) {
+	br, ok := c.bus.(interface {
+		ReadI2cBlockData(reg uint8, b []byte) (int, error)
+	})
+	if !ok {
+		return 0, sysfs.ErrI2cBlockReadNotSupported
+	}
+	if err := c.bus.SetAddress(c.address); err != nil {
+		return 0, err
+	}
+	return br.ReadI2cBlockData(reg, b)
+}
+
 // WriteBlockData writes a block of bytes to a register on the i2c device.
 func (c *i2cConnection) WriteBlockData(reg uint8, b []byte) (err error) {
 	if err := c.bus.SetAddress(c.address); err != nil {
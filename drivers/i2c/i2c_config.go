@@ -42,6 +42,8 @@ func (i *i2cConfig) GetBusOrDefault(d int) int {
 }
 
 // WithBus sets which bus to use as a optional param.
+// BusNotInitialized means the default bus of the adaptor, which allows
+// reusable lists of options to leave the bus choice to the adaptor.
 func WithBus(bus int) func(Config) {
 	return func(i Config) {
 		i.WithBus(bus)
@@ -65,6 +67,7 @@ func (i *i2cConfig) GetAddressOrDefault(a int) int {
 }
 
 // WithAddress sets which address to use as a optional param.
+// AddressNotInitialized means the default address of the driver.
 func WithAddress(address int) func(Config) {
 	return func(i Config) {
 		i.WithAddress(address)
@@ -1,6 +1,7 @@
 package i2c
 
 import (
+	"errors"
 	"testing"
 
 	"syscall"
@@ -93,8 +94,64 @@ func TestI2CWriteWordData(t *testing.T) {
 	gobottest.Assert(t, err, nil)
 }
 
+func TestI2CReadBlockData(t *testing.T) {
+	c := NewConnection(initI2CDevice(), 0x06)
+	b := make([]byte, 1)
+	n, err := c.ReadBlockData(0x01, b)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, 1)
+}
+
+func TestI2CReadI2cBlockData(t *testing.T) {
+	c := NewConnection(initI2CDevice(), 0x06)
+	_, err := c.ReadI2cBlockData(0x01, make([]byte, 1))
+	gobottest.Assert(t, err, sysfs.ErrI2cBlockReadNotSupported)
+}
+
 func TestI2CWriteBlockData(t *testing.T) {
 	c := NewConnection(initI2CDevice(), 0x06)
 	err := c.WriteBlockData(0x01, []byte{0x01, 0x02})
 	gobottest.Assert(t, err, nil)
 }
+
+// i2cScanTestConnector answers the reads of the present addresses only.
+type i2cScanTestConnector struct {
+	present map[int]bool
+	buses   []int
+}
+
+func (c *i2cScanTestConnector) GetConnection(address int, bus int) (Connection, error) {
+	if bus > 1 {
+		return nil, errors.New("invalid bus")
+	}
+	c.buses = append(c.buses, bus)
+	a := newI2cTestAdaptor()
+	a.i2cReadImpl = func(b []byte) (int, error) {
+		if !c.present[address] {
+			return 0, errors.New("no ack")
+		}
+		return len(b), nil
+	}
+	return a, nil
+}
+
+func (c *i2cScanTestConnector) GetDefaultBus() int {
+	return 1
+}
+
+func TestScanI2C(t *testing.T) {
+	c := &i2cScanTestConnector{present: map[int]bool{0x02: true, 0x3c: true, 0x76: true, 0x77: true, 0x78: true}}
+	found, err := ScanI2C(c, 0)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, found, []byte{0x3c, 0x76, 0x77})
+	gobottest.Assert(t, len(c.buses), 0x75)
+	gobottest.Assert(t, c.buses[0], 0)
+
+	c.buses = nil
+	_, err = ScanI2C(c, BusNotInitialized)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, c.buses[0], 1)
+
+	_, err = ScanI2C(c, 2)
+	gobottest.Assert(t, err, errors.New("invalid bus"))
+}
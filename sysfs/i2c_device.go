@@ -1,6 +1,7 @@
 package sysfs
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -28,6 +29,7 @@ const (
 	I2C_FUNC_SMBUS_WRITE_WORD_DATA  = 0x00400000
 	I2C_FUNC_SMBUS_READ_BLOCK_DATA  = 0x01000000
 	I2C_FUNC_SMBUS_WRITE_BLOCK_DATA = 0x02000000
+	I2C_FUNC_SMBUS_READ_I2C_BLOCK   = 0x04000000
 	// Transaction types
 	I2C_SMBUS_BYTE             = 1
 	I2C_SMBUS_BYTE_DATA        = 2
@@ -197,6 +199,48 @@ func (d *i2cDevice) WriteBlockData(reg uint8, data []byte) (err error) {
 	return nil
 }
 
+// ErrI2cBlockReadNotSupported is returned by ReadI2cBlockData if the adapter
+// does not support I2C block reads.
+var ErrI2cBlockReadNotSupported = errors.New("SMBus I2C block read not supported")
+
+// ReadBlockData reads len(b) bytes, up to 32, starting at the given register.
+// The register is written and the data read after a repeated start, in a
+// single transaction, if the adapter supports I2C block reads. Else they are
+// written and read in two transactions.
+func (d *i2cDevice) ReadBlockData(reg uint8, b []byte) (n int, err error) {
+	if len(b) <= 32 && d.funcs&I2C_FUNC_SMBUS_READ_I2C_BLOCK == 0 {
+		if _, err = d.file.Write([]byte{reg}); err != nil {
+			return 0, err
+		}
+		return d.file.Read(b)
+	}
+	return d.ReadI2cBlockData(reg, b)
+}
+
+// ReadI2cBlockData reads len(b) bytes, up to 32, starting at the given
+// register, with an SMBus I2C block read: the register is written and the
+// data read after a repeated start, in a single transaction. It returns
+// ErrI2cBlockReadNotSupported if the adapter does not support it.
+func (d *i2cDevice) ReadI2cBlockData(reg uint8, b []byte) (n int, err error) {
+	if len(b) > 32 {
+		return 0, fmt.Errorf("Reading blocks larger than 32 bytes (%v) not supported", len(b))
+	}
+	if d.funcs&I2C_FUNC_SMBUS_READ_I2C_BLOCK == 0 {
+		return 0, ErrI2cBlockReadNotSupported
+	}
+
+	// the first byte is the length, as in the block of union i2c_smbus_data.
+	var data [34]byte
+	data[0] = byte(len(b))
+	if err = d.smbusAccess(I2C_SMBUS_READ, reg, I2C_SMBUS_I2C_BLOCK_DATA, uintptr(unsafe.Pointer(&data))); err != nil {
+		return 0, err
+	}
+	if int(data[0]) < len(b) {
+		return copy(b, data[1:1+data[0]]), nil
+	}
+	return copy(b, data[1:]), nil
+}
+
 // Read implements the io.ReadWriteCloser method by direct I2C read operations.
 func (d *i2cDevice) Read(b []byte) (n int, err error) {
 	return d.file.Read(b)
@@ -223,7 +267,7 @@ func (d *i2cDevice) smbusAccess(readWrite byte, command byte, size uint32, data
 	)
 
 	if errno != 0 {
-		return fmt.Errorf("Failed with syscall.Errno %v", errno)
+		return fmt.Errorf("Failed with syscall.Errno %w", errno)
 	}
 
 	return nil
@@ -2,7 +2,9 @@ package sysfs
 
 import (
 	"os"
+	"syscall"
 	"testing"
+	"unsafe"
 
 	"gobot.io/x/gobot/gobottest"
 )
@@ -47,3 +49,53 @@ func TestNewI2cDevice(t *testing.T) {
 	gobottest.Assert(t, err, nil)
 
 }
+
+func TestI2cDeviceReadBlockData(t *testing.T) {
+	fs := NewMockFilesystem([]string{
+		"/dev/i2c-1",
+	})
+	SetFilesystem(fs)
+
+	// without I2C block reads, the register is written and then read.
+	SetSyscall(&MockSyscall{})
+	i, err := NewI2cDevice("/dev/i2c-1")
+	gobottest.Assert(t, err, nil)
+	buf := make([]byte, 1)
+	n, err := i.ReadBlockData(0x88, buf)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, 1)
+	gobottest.Assert(t, buf, []byte{0x88})
+	_, err = i.ReadI2cBlockData(0x88, buf)
+	gobottest.Assert(t, err, ErrI2cBlockReadNotSupported)
+
+	var command byte
+	SetSyscall(&MockSyscall{
+		Impl: func(trap, a1, a2, a3 uintptr) (r1, r2 uintptr, err syscall.Errno) {
+			switch a2 {
+			case I2C_FUNCS:
+				*(*uint64)(unsafe.Pointer(a3)) = I2C_FUNC_SMBUS_READ_I2C_BLOCK
+			case I2C_SMBUS:
+				smbus := (*i2cSmbusIoctlData)(unsafe.Pointer(a3))
+				command = smbus.command
+				block := (*[34]byte)(unsafe.Pointer(smbus.data))
+				copy(block[1:], []byte{0x01, 0x02, 0x03})
+			}
+			return 0, 0, 0
+		},
+	})
+	i, err = NewI2cDevice("/dev/i2c-1")
+	gobottest.Assert(t, err, nil)
+	buf = make([]byte, 3)
+	n, err = i.ReadBlockData(0xf7, buf)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, 3)
+	gobottest.Assert(t, buf, []byte{0x01, 0x02, 0x03})
+	gobottest.Assert(t, command, byte(0xf7))
+	n, err = i.ReadI2cBlockData(0xf8, buf)
+	gobottest.Assert(t, err, nil)
+	gobottest.Assert(t, n, 3)
+	gobottest.Assert(t, command, byte(0xf8))
+
+	_, err = i.ReadBlockData(0xf7, make([]byte, 33))
+	gobottest.Refute(t, err, nil)
+}